@@ -0,0 +1,549 @@
+package main
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const RUN_LINES = 1_000_000
+const MERGE_FAN_IN = 64
+
+// Comparison holds every pairwise score between the columns of a location
+// list. Distance[i][j] compares the sorted columns i and j position by
+// position with the chosen metric, Similarity[i][j] sums each number in
+// column i times how often it appears in column j.
+type Comparison struct {
+	Columns    int     `json:"columns"`
+	Metric     string  `json:"metric"`
+	Distance   [][]int `json:"distance"`
+	Similarity [][]int `json:"similarity"`
+}
+
+// metrics fold the difference between two paired numbers into a running total.
+var metrics = map[string]func(total int, diff int) int{
+	"distance": func(total, diff int) int { return total + absInt(diff) },
+	"squared":  func(total, diff int) int { return total + diff*diff },
+	"max":      func(total, diff int) int { return max(total, absInt(diff)) },
+}
+
+func main() {
+	inputPath := flag.String("input", "input.txt", "location list to compare")
+	external := flag.Bool("external", false, "sort through temporary run files instead of in memory")
+	runLines := flag.Int("run-lines", RUN_LINES, "lines sorted in memory per run file in -external mode")
+	metric := flag.String("metric", "distance", "pairwise distance metric: distance, squared or max")
+	format := flag.String("format", "table", "output format: table or json")
+	flag.Parse()
+
+	if _, exists := metrics[*metric]; !exists {
+		fmt.Printf("Unknown metric: %s\n", *metric)
+		return
+	}
+	if *format != "table" && *format != "json" {
+		fmt.Printf("Unknown format: %s\n", *format)
+		return
+	}
+	if *runLines < 1 {
+		fmt.Printf("Run lines must be at least 1, got %d\n", *runLines)
+		return
+	}
+
+	// Open the input file
+	file, err := os.Open(*inputPath)
+	if err != nil {
+		fmt.Printf("Error opening file: %v\n", err)
+		return
+	}
+	defer file.Close()
+
+	var comparison Comparison
+	if *external {
+		comparison, err = externalComparison(file, *runLines, *metric)
+	} else {
+		comparison, err = inMemoryComparison(file, *metric)
+	}
+	if err != nil {
+		fmt.Printf("Error reading file: %v\n", err)
+		return
+	}
+
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(comparison)
+		return
+	}
+	printMatrix(fmt.Sprintf("Total error (%s)", comparison.Metric), comparison.Distance)
+	printMatrix("Similiarty Score", comparison.Similarity)
+}
+
+func printMatrix(title string, matrix [][]int) {
+	fmt.Printf("%s:\n", title)
+	cells := make([][]string, len(matrix)+1)
+	cells[0] = append(cells[0], "")
+	for j := range matrix {
+		cells[0] = append(cells[0], fmt.Sprintf("col%d", j+1))
+	}
+	for i, row := range matrix {
+		cells[i+1] = append(cells[i+1], fmt.Sprintf("col%d", i+1))
+		for _, value := range row {
+			cells[i+1] = append(cells[i+1], strconv.Itoa(value))
+		}
+	}
+
+	width := 0
+	for _, row := range cells {
+		for _, cell := range row {
+			width = max(width, len(cell))
+		}
+	}
+	for _, row := range cells {
+		for _, cell := range row {
+			fmt.Printf(" %*s", width, cell)
+		}
+		fmt.Println()
+	}
+}
+
+func inMemoryComparison(file *os.File, metric string) (Comparison, error) {
+	columns, err := readColumns(file)
+	if err != nil {
+		return Comparison{}, err
+	}
+
+	for _, column := range columns {
+		sort.Ints(column)
+	}
+
+	return compareColumns(len(columns), metric, func(column int) (sortedColumn, error) {
+		return &sliceColumn{numbers: columns[column]}, nil
+	})
+}
+
+func readColumns(file *os.File) ([][]int, error) {
+	var columns [][]int
+
+	// Read the file line by line
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		nums, ok := parseLine(scanner.Text(), len(columns))
+		if !ok {
+			continue
+		}
+
+		if columns == nil {
+			columns = make([][]int, len(nums))
+		}
+		for i, num := range nums {
+			columns[i] = append(columns[i], num)
+		}
+	}
+
+	return columns, scanner.Err()
+}
+
+// parseLine reads one row of numbers. Once the first row has fixed the number
+// of columns, rows with a different count are skipped as malformed.
+func parseLine(line string, numColumns int) ([]int, bool) {
+	// Split the line into fields
+	fields := strings.Fields(line)
+	if len(fields) == 0 || (numColumns > 0 && len(fields) != numColumns) {
+		fmt.Printf("Skipping malformed line: %s\n", line)
+		return nil, false
+	}
+
+	// Convert fields to integers
+	nums := make([]int, len(fields))
+	for i, field := range fields {
+		num, err := strconv.Atoi(field)
+		if err != nil {
+			fmt.Printf("Error converting column %d to int: %v\n", i+1, err)
+			return nil, false
+		}
+		nums[i] = num
+	}
+
+	return nums, true
+}
+
+// sortedColumn yields one column's numbers in ascending order. A column is
+// read once per pairing, so open returns a fresh one each time.
+type sortedColumn interface {
+	Next() (int, bool)
+	Close() error
+}
+
+type sliceColumn struct {
+	numbers []int
+	next    int
+}
+
+func (c *sliceColumn) Next() (int, bool) {
+	if c.next == len(c.numbers) {
+		return 0, false
+	}
+	c.next++
+	return c.numbers[c.next-1], true
+}
+
+func (c *sliceColumn) Close() error {
+	return nil
+}
+
+func compareColumns(numColumns int, metric string, open func(column int) (sortedColumn, error)) (Comparison, error) {
+	comparison := Comparison{
+		Columns:    numColumns,
+		Metric:     metric,
+		Distance:   make([][]int, numColumns),
+		Similarity: make([][]int, numColumns),
+	}
+	for i := range numColumns {
+		comparison.Distance[i] = make([]int, numColumns)
+		comparison.Similarity[i] = make([]int, numColumns)
+	}
+
+	for i := range numColumns {
+		for j := range numColumns {
+			if i < j {
+				distance, err := scorePair(i, j, open, func(column1, column2 sortedColumn) int {
+					return totalDistance(column1, column2, metrics[metric])
+				})
+				if err != nil {
+					return Comparison{}, err
+				}
+				comparison.Distance[i][j] = distance
+				comparison.Distance[j][i] = distance
+			}
+
+			similarity, err := scorePair(i, j, open, similarityScore)
+			if err != nil {
+				return Comparison{}, err
+			}
+			comparison.Similarity[i][j] = similarity
+		}
+	}
+
+	return comparison, nil
+}
+
+func scorePair(i, j int, open func(column int) (sortedColumn, error), score func(column1, column2 sortedColumn) int) (int, error) {
+	column1, err := open(i)
+	if err != nil {
+		return 0, err
+	}
+	column2, err := open(j)
+	if err != nil {
+		column1.Close()
+		return 0, err
+	}
+
+	result := score(column1, column2)
+
+	err1 := column1.Close()
+	err2 := column2.Close()
+	if err1 != nil {
+		return 0, err1
+	}
+	return result, err2
+}
+
+// totalDistance pairs up the sorted columns position by position.
+func totalDistance(column1, column2 sortedColumn, metric func(total int, diff int) int) int {
+	var totalerror int = 0
+	for {
+		num1, ok1 := column1.Next()
+		num2, ok2 := column2.Next()
+		if !ok1 || !ok2 {
+			break
+		}
+		totalerror = metric(totalerror, num1-num2)
+	}
+	return totalerror
+}
+
+// similarityScore walks both sorted columns together, so every run of equal
+// values is matched once instead of rescanning column2 for each number.
+func similarityScore(column1, column2 sortedColumn) int {
+	var similarityScore int = 0
+	num1, ok1 := column1.Next()
+	num2, ok2 := column2.Next()
+	for ok1 && ok2 {
+		switch {
+		case num1 < num2:
+			num1, ok1 = column1.Next()
+		case num1 > num2:
+			num2, ok2 = column2.Next()
+		default:
+			n := num1
+			timesInList1 := 0
+			for ok1 && num1 == n {
+				timesInList1++
+				num1, ok1 = column1.Next()
+			}
+			timesInList2 := 0
+			for ok2 && num2 == n {
+				timesInList2++
+				num2, ok2 = column2.Next()
+			}
+			similarityScore += n * timesInList1 * timesInList2
+		}
+	}
+	return similarityScore
+}
+
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// externalComparison computes the same comparison without holding the columns
+// in memory. Each column is sorted runLines at a time into run files, and
+// every pairing then k-way merges the runs of its two columns.
+func externalComparison(file *os.File, runLines int, metric string) (Comparison, error) {
+	dir, err := os.MkdirTemp("", "day01-runs")
+	if err != nil {
+		return Comparison{}, err
+	}
+	defer os.RemoveAll(dir)
+
+	runs, err := writeSortedRuns(file, dir, runLines)
+	if err != nil {
+		return Comparison{}, err
+	}
+	for i := range runs {
+		if runs[i], err = reduceRuns(runs[i]); err != nil {
+			return Comparison{}, err
+		}
+	}
+
+	return compareColumns(len(runs), metric, func(column int) (sortedColumn, error) {
+		return openMergedRuns(runs[column])
+	})
+}
+
+// writeSortedRuns splits the input into sorted run files, one per column per
+// runLines lines, and returns their paths grouped by column.
+func writeSortedRuns(file *os.File, dir string, runLines int) ([][]string, error) {
+	var runs [][]string
+	var columns [][]int
+	lines := 0
+
+	flush := func() error {
+		if lines == 0 {
+			return nil
+		}
+		for i, column := range columns {
+			sort.Ints(column)
+			path := filepath.Join(dir, fmt.Sprintf("column%d-%d.run", i+1, len(runs[i])))
+			if err := writeRun(path, column); err != nil {
+				return err
+			}
+			runs[i] = append(runs[i], path)
+			columns[i] = column[:0]
+		}
+		lines = 0
+		return nil
+	}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		nums, ok := parseLine(scanner.Text(), len(columns))
+		if !ok {
+			continue
+		}
+
+		if columns == nil {
+			runs = make([][]string, len(nums))
+			columns = make([][]int, len(nums))
+			for i := range columns {
+				columns[i] = make([]int, 0, runLines)
+			}
+		}
+		for i, num := range nums {
+			columns[i] = append(columns[i], num)
+		}
+		lines++
+		if lines == runLines {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return runs, flush()
+}
+
+func writeRun(path string, numbers []int) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	var buf [8]byte
+	for _, n := range numbers {
+		binary.LittleEndian.PutUint64(buf[:], uint64(n))
+		if _, err := writer.Write(buf[:]); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// reduceRuns merges runs MERGE_FAN_IN at a time until few enough remain to be
+// merged in one go, keeping the number of open files bounded.
+func reduceRuns(runs []string) ([]string, error) {
+	for len(runs) > MERGE_FAN_IN {
+		var merged []string
+		for start := 0; start < len(runs); start += MERGE_FAN_IN {
+			end := min(start+MERGE_FAN_IN, len(runs))
+			path := strings.TrimSuffix(runs[start], ".run") + fmt.Sprintf("-%d.run", len(runs))
+			if err := mergeRunsInto(path, runs[start:end]); err != nil {
+				return nil, err
+			}
+			merged = append(merged, path)
+		}
+		runs = merged
+	}
+	return runs, nil
+}
+
+func mergeRunsInto(path string, runs []string) error {
+	column, err := openMergedRuns(runs)
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		column.Close()
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	var buf [8]byte
+	for n, ok := column.Next(); ok; n, ok = column.Next() {
+		binary.LittleEndian.PutUint64(buf[:], uint64(n))
+		if _, err = writer.Write(buf[:]); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if closeErr := column.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, run := range runs {
+		os.Remove(run)
+	}
+	return nil
+}
+
+// MergedRuns yields the numbers of several sorted run files in sorted order.
+// Like bufio.Scanner, Next reports false at the end or on a read error, and
+// the error is returned by Close.
+type MergedRuns struct {
+	files   []*os.File
+	readers []*bufio.Reader
+	heads   runHeap
+	err     error
+}
+
+type runHead struct {
+	value int
+	run   int
+}
+
+type runHeap []runHead
+
+func (h runHeap) Len() int           { return len(h) }
+func (h runHeap) Less(i, j int) bool { return h[i].value < h[j].value }
+func (h runHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *runHeap) Push(x any)        { *h = append(*h, x.(runHead)) }
+func (h *runHeap) Pop() any {
+	old := *h
+	head := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return head
+}
+
+func openMergedRuns(runs []string) (*MergedRuns, error) {
+	m := &MergedRuns{}
+	for i, run := range runs {
+		file, err := os.Open(run)
+		if err != nil {
+			m.Close()
+			return nil, err
+		}
+		m.files = append(m.files, file)
+		m.readers = append(m.readers, bufio.NewReader(file))
+		if value, ok := m.readRun(i); ok {
+			m.heads = append(m.heads, runHead{value: value, run: i})
+		}
+	}
+	if m.err != nil {
+		err := m.err
+		m.Close()
+		return nil, err
+	}
+	heap.Init(&m.heads)
+	return m, nil
+}
+
+func (m *MergedRuns) readRun(run int) (int, bool) {
+	var buf [8]byte
+	if _, err := io.ReadFull(m.readers[run], buf[:]); err != nil {
+		if err != io.EOF {
+			m.err = err
+		}
+		return 0, false
+	}
+	return int(binary.LittleEndian.Uint64(buf[:])), true
+}
+
+func (m *MergedRuns) Next() (int, bool) {
+	if len(m.heads) == 0 || m.err != nil {
+		return 0, false
+	}
+	head := m.heads[0]
+	if value, ok := m.readRun(head.run); ok {
+		m.heads[0].value = value
+		heap.Fix(&m.heads, 0)
+	} else {
+		heap.Pop(&m.heads)
+	}
+	return head.value, m.err == nil
+}
+
+func (m *MergedRuns) Close() error {
+	for _, file := range m.files {
+		file.Close()
+	}
+	return m.err
+}
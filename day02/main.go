@@ -2,14 +2,87 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
 )
 
+// Violation is the first pair of adjacent levels that breaks the safety rules.
+type Violation struct {
+	Index  int    `json:"index"`
+	Pair   [2]int `json:"pair"`
+	Reason string `json:"reason"`
+}
+
+// Report explains the verdict for one line of the input. Violation is the
+// first problem in the undampened levels, and Removed holds the indexes of the
+// levels the Problem Dampener takes out to make the report safe.
+type Report struct {
+	Line      int        `json:"line"`
+	Levels    []int      `json:"levels"`
+	Verdict   string     `json:"verdict"`
+	Violation *Violation `json:"violation,omitempty"`
+	Removed   []int      `json:"removed,omitempty"`
+}
+
+// Policy is what makes a report safe: every step between adjacent levels is
+// between MinStep and MaxStep and goes in one allowed direction, after the
+// Problem Dampener removes at most Tolerance levels.
+type Policy struct {
+	MinStep    int
+	MaxStep    int
+	Increasing bool
+	Decreasing bool
+	Tolerance  int
+}
+
+const INCREASING = 1
+const DECREASING = -1
+
+func (p Policy) allows(direction int) bool {
+	return (direction == INCREASING && p.Increasing) || (direction == DECREASING && p.Decreasing)
+}
+
+const SAFE = "safe"
+const DAMPENED = "dampened"
+const UNSAFE = "unsafe"
+
 func main() {
-	file, err := os.Open("input.txt")
+	inputPath := flag.String("input", "input.txt", "reports to check")
+	report := flag.Bool("report", false, "explain the verdict for every report")
+	format := flag.String("format", "table", "report format: table or json")
+	minStep := flag.Int("min-step", 1, "smallest allowed step between adjacent levels")
+	maxStep := flag.Int("max-step", 3, "largest allowed step between adjacent levels")
+	directions := flag.String("directions", "increasing,decreasing", "allowed directions, comma separated")
+	tolerance := flag.Int("tolerance", 1, "levels the Problem Dampener may remove")
+	flag.Parse()
+
+	if *format != "table" && *format != "json" {
+		fmt.Printf("Unknown format: %s\n", *format)
+		return
+	}
+	if *tolerance < 0 {
+		fmt.Printf("Tolerance can't be negative, got %d\n", *tolerance)
+		return
+	}
+
+	policy := Policy{MinStep: *minStep, MaxStep: *maxStep, Tolerance: *tolerance}
+	for _, direction := range strings.Split(*directions, ",") {
+		switch direction {
+		case "increasing":
+			policy.Increasing = true
+		case "decreasing":
+			policy.Decreasing = true
+		default:
+			fmt.Printf("Unknown direction: %s\n", direction)
+			return
+		}
+	}
+
+	file, err := os.Open(*inputPath)
 	if err != nil {
 		fmt.Printf("Error opening file: %v\n", err)
 		return
@@ -17,11 +90,14 @@ func main() {
 	defer file.Close()
 
 	// Read the file line by line
+	var reports []Report
 	numSafe := 0
+	lineNum := 0
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		var levels []int
 		line := scanner.Text()
+		lineNum++
 
 		fields := strings.Fields(line)
 
@@ -30,52 +106,166 @@ func main() {
 			levels = append(levels, level)
 		}
 
-		fmt.Println(levels)
-		safe := checkLevelSafety(levels)
+		r := checkLevelSafety(levels, policy)
+		r.Line = lineNum
+		reports = append(reports, r)
 
-		if safe {
+		if r.Verdict != UNSAFE {
 			numSafe++
 		}
 	}
 
+	if *report {
+		if *format == "json" {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			encoder.Encode(reports)
+			return
+		}
+		printReports(reports)
+	}
+
 	fmt.Printf("Total number safe [%d].\n", numSafe)
 
 }
 
-func checkLevelSafety(levels []int) bool {
-	if checkDampenedLevelSafety(levels) {
-		return true // if it works without removing anything it's good
+// checkLevelSafety finds the fewest levels to remove so that the rest satisfy
+// the policy, trying each allowed direction in turn.
+func checkLevelSafety(levels []int, policy Policy) Report {
+	r := Report{Levels: levels, Verdict: SAFE}
+	r.Violation = findViolation(levels, policy)
+	if r.Violation == nil {
+		return r // if it works without removing anything it's good
 	}
 
-	for i := 0; i < len(levels); i++ {
-		newLevels := append([]int{}, levels[:i]...)
-		newLevels = append(newLevels, levels[i+1:]...)
-		if checkDampenedLevelSafety(newLevels) {
-			fmt.Printf("It's safe!\n")
-			return true // if it worked like this it's fine
+	r.Verdict = UNSAFE
+	for _, direction := range []int{INCREASING, DECREASING} {
+		if !policy.allows(direction) {
+			continue
+		}
+		kept := keepMostLevels(levels, policy, direction)
+		if kept == nil || len(levels)-len(kept) > policy.Tolerance {
+			continue
+		}
+		if r.Verdict == UNSAFE || len(levels)-len(kept) < len(r.Removed) {
+			r.Verdict = DAMPENED
+			r.Removed = removedIndexes(len(levels), kept)
 		}
 	}
-	return false // if we made it here, it is bad
+	return r
 }
 
-func checkDampenedLevelSafety(levels []int) bool {
+// keepMostLevels returns the indexes of the longest run of levels that moves
+// in direction within the policy's steps, removing at most policy.Tolerance
+// levels. best[i] is the fewest removals before level i when i is kept, and
+// only the previous Tolerance+1 levels can precede it, so this is O(n*k).
+func keepMostLevels(levels []int, policy Policy, direction int) []int {
+	n := len(levels)
+	if n == 0 {
+		return []int{}
+	}
+
+	best := make([]int, n)
+	previous := make([]int, n)
+	for i := range levels {
+		best[i] = i // drop everything before i
+		previous[i] = -1
+		for j := max(0, i-policy.Tolerance-1); j < i; j++ {
+			step := (levels[i] - levels[j]) * direction
+			if step < policy.MinStep || step > policy.MaxStep {
+				continue
+			}
+			if removals := best[j] + i - j - 1; removals < best[i] {
+				best[i] = removals
+				previous[i] = j
+			}
+		}
+	}
+
+	last := -1
+	for i := range levels {
+		removals := best[i] + n - 1 - i
+		if removals <= policy.Tolerance && (last == -1 || removals < best[last]+n-1-last) {
+			last = i
+		}
+	}
+	if last == -1 {
+		return nil
+	}
+
+	var kept []int
+	for i := last; i != -1; i = previous[i] {
+		kept = append([]int{i}, kept...)
+	}
+	return kept
+}
+
+func removedIndexes(n int, kept []int) []int {
+	removed := []int{}
+	k := 0
+	for i := 0; i < n; i++ {
+		if k < len(kept) && kept[k] == i {
+			k++
+		} else {
+			removed = append(removed, i)
+		}
+	}
+	return removed
+}
+
+func findViolation(levels []int, policy Policy) *Violation {
 	direction := 0
 	for i := 0; i < len(levels)-1; i++ {
-		diff := levels[i] - levels[i+1]
-		absDiff, sign := absInt(diff)
-		if direction != 0 {
-			if sign != direction {
-				//fmt.Printf("Unsafe because sign of [%d] between pair [%d,%d] changed from previous.\n", sign, levels[i], levels[i+1])
-				return false
+		step, sign := absInt(levels[i+1] - levels[i])
+		if step == 0 {
+			sign = 0 // a flat step doesn't pick a direction
+		}
+		pair := [2]int{levels[i], levels[i+1]}
+		if sign != 0 {
+			if direction != 0 && sign != direction {
+				return &Violation{Index: i, Pair: pair, Reason: "direction flip"}
+			}
+			if direction == 0 && !policy.allows(sign) {
+				return &Violation{Index: i, Pair: pair, Reason: "direction not allowed"}
 			}
+			direction = sign
 		}
-		if absDiff < 1 || absDiff > 3 {
-			//fmt.Printf("Unsafe because diff of [%d] between pair [%d,%d] is out of tolerance.\n", absDiff, levels[i], levels[i+1])
-			return false // unsafe because amount of increase
+		if step < policy.MinStep || step > policy.MaxStep {
+			return &Violation{Index: i, Pair: pair, Reason: fmt.Sprintf("step of %d out of range", step)}
 		}
-		direction = sign
 	}
-	return true
+	return nil
+}
+
+func printReports(reports []Report) {
+	width := 0
+	for _, r := range reports {
+		width = max(width, len(joinLevels(r.Levels)))
+	}
+
+	for _, r := range reports {
+		line := fmt.Sprintf("%5d  %-*s  %-8s", r.Line, width, joinLevels(r.Levels), r.Verdict)
+		if r.Violation != nil {
+			v := r.Violation
+			line += fmt.Sprintf("  %d,%d at index %d: %s", v.Pair[0], v.Pair[1], v.Index, v.Reason)
+		}
+		if r.Removed != nil {
+			label := "index"
+			if len(r.Removed) > 1 {
+				label = "indexes"
+			}
+			line += fmt.Sprintf("; fixed by removing %s %s", label, joinLevels(r.Removed))
+		}
+		fmt.Println(strings.TrimRight(line, " "))
+	}
+}
+
+func joinLevels(levels []int) string {
+	fields := make([]string, len(levels))
+	for i, level := range levels {
+		fields[i] = strconv.Itoa(level)
+	}
+	return strings.Join(fields, " ")
 }
 
 func absInt(n int) (int, int) {
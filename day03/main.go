@@ -1,109 +1,283 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"io"
 	"os"
-	"regexp"
-	"strconv"
-	"strings"
 )
 
+// Instruction is one function the corrupted memory can call, written as
+// name(arg,...) with Arity arguments of 1-3 digits each. Apply runs it and
+// returns the value it produced, if any.
+type Instruction struct {
+	Name  string
+	Arity int
+	Apply func(m *Machine, args []int) int
+}
+
+// instructions is the instruction set the lexer recognizes. Adding a new one
+// (say add(a,b) or a three operand mul) only needs another entry here.
+var instructions = []Instruction{
+	{Name: "mul", Arity: 2, Apply: func(m *Machine, args []int) int {
+		product := args[0] * args[1]
+		m.Part1 += product
+		if m.Enabled {
+			m.Part2 += product
+		}
+		return product
+	}},
+	{Name: "do", Arity: 0, Apply: func(m *Machine, args []int) int {
+		m.Enabled = true
+		return 0
+	}},
+	{Name: "don't", Arity: 0, Apply: func(m *Machine, args []int) int {
+		m.Enabled = false
+		return 0
+	}},
+}
+
+const MAX_DIGITS = 3
+const BUFFER_SIZE = 4096
+
+const (
+	matchFailed = iota
+	matchPartial
+	matchComplete
+)
+
+// Match is the result of lexing at the front of the pending bytes. When a
+// candidate got as far as name( before failing, Reason says why it was
+// rejected and Length covers the bytes examined.
+type Match struct {
+	Status      int
+	Instruction *Instruction
+	Args        []int
+	Length      int
+	Reason      string
+}
+
+// Machine runs the instructions found in the bytes written to it. Part1 adds
+// up every mul, Part2 only those made while enabled. If Trace is set, every
+// accepted instruction and rejected candidate is written to it.
+type Machine struct {
+	Part1   int
+	Part2   int
+	Enabled bool
+	Trace   io.Writer
+	pending []byte
+	offset  int // input offset of pending[0]
+}
+
+func NewMachine() *Machine {
+	return &Machine{Enabled: true}
+}
+
 func main() {
-	filePath := "input.txt"
+	filePath := flag.String("input", "input.txt", "corrupted memory to scan")
+	bufferSize := flag.Int("buffer", BUFFER_SIZE, "bytes read from the input at a time")
+	trace := flag.Bool("trace", false, "print every accepted instruction and rejected candidate")
+	flag.Parse()
+
+	if *bufferSize < 1 {
+		fmt.Printf("Buffer size must be at least 1, got %d\n", *bufferSize)
+		return
+	}
 
 	// Open the file
-	file, err := os.Open(filePath)
+	file, err := os.Open(*filePath)
 	if err != nil {
 		fmt.Printf("Error opening file: %v\n", err)
 		return
 	}
 	defer file.Close() // Ensure the file is closed when done
 
-	// Read the entire file into a string
-	data, err := io.ReadAll(file)
+	machine := NewMachine()
+	if *trace {
+		machine.Trace = os.Stdout
+	}
+	err = machine.Scan(file, *bufferSize)
 	if err != nil {
 		fmt.Printf("Error reading file: %v\n", err)
 		return
 	}
 
-	input := string(data)
-	total := 0
-
-	// remove disabled instructions
-	re := regexp.MustCompile(`don't\(\)[\s\S]*?do\(\)`)
-	input = re.ReplaceAllString(input, "DISABLED")
-
-	// process the instructions
-	indexes := findAllStartIndexes(input, "mul(")
-
-	fmt.Println("Indexes of mul: ", indexes)
-	for i := range indexes {
-		mul := indexes[i]
-		fmt.Printf("processing mul location %d\n", mul)
-		closingParen := mul + strings.Index(input[mul:], ")")
-		fmt.Printf("    closing parent location is %d\n", closingParen)
-		if closingParen != -1 {
-			// found a closing paren
-			candidateInstruction := input[mul : closingParen+1]
-			args := candidateInstruction[4 : len(candidateInstruction)-1]
-			fmt.Printf("    found candidate instruction %s, args %s\n", candidateInstruction, args)
-			first, second, err := validateAndSplit(args)
-			if err != nil {
-				fmt.Println("Skipping args because error:", err)
-			} else {
-				fmt.Printf("First integer: %d, Second integer: %d\n", first, second)
-				total += first * second
-			}
+	fmt.Printf("Part 1 total [%d].\n", machine.Part1)
+	fmt.Printf("Part 2 total [%d].\n", machine.Part2)
+}
+
+// Scan runs the instructions in r, reading bufferSize bytes at a time. An
+// instruction cut in two by a buffer boundary is held until the rest arrives,
+// so memory use doesn't depend on the size of the input.
+func (m *Machine) Scan(r io.Reader, bufferSize int) error {
+	if bufferSize < 1 {
+		return fmt.Errorf("buffer size must be at least 1, got %d", bufferSize)
+	}
+	buf := make([]byte, bufferSize)
+	for {
+		n, err := r.Read(buf)
+		m.Write(buf[:n])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
 		}
 	}
+	m.Flush()
+	return nil
+}
 
-	fmt.Printf("Total [%d].\n", total)
-
+// Write feeds memory to the lexer a byte at a time. Only a possible
+// instruction that is still incomplete is held back until more bytes arrive.
+func (m *Machine) Write(p []byte) (int, error) {
+	for _, b := range p {
+		m.pending = append(m.pending, b)
+		m.scan(false)
+	}
+	return len(p), nil
 }
 
-func findAllStartIndexes(s, substr string) []int {
-	var indexes []int
-	start := 0
+// Flush gives up on whatever partial instruction is still held at the end of
+// the input.
+func (m *Machine) Flush() {
+	m.scan(true)
+}
 
-	for {
-		// Search for the substring starting from the current index
-		idx := strings.Index(s[start:], substr)
-		if idx == -1 {
-			// No more occurrences found
-			break
+func (m *Machine) scan(final bool) {
+	for len(m.pending) > 0 {
+		match := matchAt(m.pending)
+		if match.Status == matchPartial && !final {
+			return // wait for more bytes
+		}
+		if match.Status == matchComplete {
+			value := match.Instruction.Apply(m, match.Args)
+			m.traceAccepted(match, value)
+			m.advance(match.Length)
+			continue
+		}
+		if match.Reason != "" {
+			m.traceRejected(match)
 		}
-		// Calculate the actual index in the original string
-		actualIndex := start + idx
-		indexes = append(indexes, actualIndex)
-		// Move the start index past the current match
-		start = actualIndex + len(substr)
+		m.advance(1)
 	}
+}
+
+func (m *Machine) advance(n int) {
+	m.pending = m.pending[n:]
+	m.offset += n
+}
+
+func (m *Machine) traceAccepted(match Match, value int) {
+	if m.Trace == nil {
+		return
+	}
+	state := "enabled"
+	if !m.Enabled {
+		state = "disabled"
+	}
+	line := fmt.Sprintf("%8d  %-16s", m.offset, m.pending[:match.Length])
+	if match.Instruction.Arity > 0 {
+		line += fmt.Sprintf("  args %v = %d", match.Args, value)
+	}
+	fmt.Fprintf(m.Trace, "%s  [%s]\n", line, state)
+}
+
+func (m *Machine) traceRejected(match Match) {
+	if m.Trace == nil {
+		return
+	}
+	fmt.Fprintf(m.Trace, "%8d  rejected %q: %s\n", m.offset, m.pending[:match.Length], match.Reason)
+}
 
-	return indexes
+// matchAt tries every instruction at the start of buf. It reports
+// matchPartial if buf ends before any of them could be ruled out.
+func matchAt(buf []byte) Match {
+	result := Match{Status: matchFailed}
+	for i := range instructions {
+		match := matchInstruction(buf, &instructions[i])
+		if match.Status == matchComplete {
+			return match
+		}
+		if match.Status == matchPartial {
+			result.Status = matchPartial
+		}
+		if match.Reason != "" {
+			result.Instruction = match.Instruction
+			result.Length = match.Length
+			result.Reason = match.Reason
+		}
+	}
+	return result
 }
 
-func validateAndSplit(args string) (int, int, error) {
-	// Split the string on commas
-	parts := strings.Split(args, ",")
+func matchInstruction(buf []byte, instruction *Instruction) Match {
+	prefix := instruction.Name + "("
+	for i := 0; i < len(prefix); i++ {
+		if i == len(buf) {
+			return Match{Status: matchPartial}
+		}
+		if buf[i] != prefix[i] {
+			return Match{Status: matchFailed}
+		}
+	}
 
-	// Check if we have exactly two fields
-	if len(parts) != 2 {
-		return 0, 0, fmt.Errorf("input must contain exactly two fields separated by a comma")
+	// from here on we have a candidate, so say why if it doesn't pan out
+	rejected := func(status int, pos int, reason string) Match {
+		return Match{Status: status, Instruction: instruction, Length: min(pos+1, len(buf)), Reason: reason}
 	}
 
-	// Parse and validate each field
-	first, err1 := strconv.Atoi(parts[0])
-	second, err2 := strconv.Atoi(parts[1])
+	pos := len(prefix)
+	args := make([]int, 0, instruction.Arity)
+	for a := 0; a < instruction.Arity; a++ {
+		arg, digits := 0, 0
+		for pos < len(buf) && isDigit(buf[pos]) {
+			if digits == MAX_DIGITS {
+				return rejected(matchFailed, pos, "too many digits")
+			}
+			arg = arg*10 + int(buf[pos]-'0')
+			digits++
+			pos++
+		}
+		if pos == len(buf) {
+			return rejected(matchPartial, pos, "input ends mid instruction")
+		}
+		if digits == 0 {
+			return rejected(matchFailed, pos, unexpected(buf[pos], "missing argument"))
+		}
+		args = append(args, arg)
+
+		if a < instruction.Arity-1 {
+			if buf[pos] != ',' {
+				if buf[pos] == ')' {
+					return rejected(matchFailed, pos, "too few arguments")
+				}
+				return rejected(matchFailed, pos, unexpected(buf[pos], "missing comma"))
+			}
+			pos++
+		}
+	}
 
-	if err1 != nil || err2 != nil {
-		return 0, 0, fmt.Errorf("both fields must be valid integers")
+	if pos == len(buf) {
+		return rejected(matchPartial, pos, "input ends mid instruction")
 	}
+	if buf[pos] != ')' {
+		if buf[pos] == ',' {
+			return rejected(matchFailed, pos, "too many arguments")
+		}
+		return rejected(matchFailed, pos, unexpected(buf[pos], "missing closing paren"))
+	}
+	return Match{Status: matchComplete, Instruction: instruction, Args: args, Length: pos + 1}
+}
 
-	// Ensure no extra whitespace around the original fields
-	if parts[0] != strconv.Itoa(first) || parts[1] != strconv.Itoa(second) {
-		return 0, 0, fmt.Errorf("fields must be integers with no whitespace or extra characters")
+// unexpected names the problem with b, calling out whitespace specifically.
+func unexpected(b byte, reason string) string {
+	if b == ' ' || b == '\t' || b == '\n' || b == '\r' {
+		return "whitespace"
 	}
+	return reason
+}
 
-	return first, second, nil
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
 }
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+type totals struct {
+	part1   int
+	part2   int
+	enabled bool
+}
+
+func scanAll(t *testing.T, r io.Reader, bufferSize int) totals {
+	t.Helper()
+	m := NewMachine()
+	if err := m.Scan(r, bufferSize); err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	return totals{m.Part1, m.Part2, m.Enabled}
+}
+
+func testInputs(t *testing.T) map[string]string {
+	t.Helper()
+	sample, err := os.ReadFile("sample.txt")
+	if err != nil {
+		t.Fatalf("reading sample: %v", err)
+	}
+	return map[string]string{
+		"sample":             string(sample),
+		"trailing don't":     "mul(2,3)don't()",
+		"cut mid digit":      "mul(12,3",
+		"cut then complete":  "mul(12mul(3,4)",
+		"too many digits":    "mul(1234,5)mul(6,7)",
+		"nested candidates":  "mumul(mul(2,2))",
+		"toggles":            "don't()mul(1,1)do()mul(2,2)don't()",
+		"whitespace in args": "mul( 2,3)mul(4 ,5)mul(6,7)",
+	}
+}
+
+func TestSample(t *testing.T) {
+	sample, err := os.ReadFile("sample.txt")
+	if err != nil {
+		t.Fatalf("reading sample: %v", err)
+	}
+	got := scanAll(t, bytes.NewReader(sample), BUFFER_SIZE)
+	if got.part1 != 161 || got.part2 != 48 {
+		t.Errorf("got part 1 %d, part 2 %d, want 161 and 48", got.part1, got.part2)
+	}
+}
+
+// TestEverySplit feeds each input in two reads, split at every byte offset,
+// and expects the same totals as a single read.
+func TestEverySplit(t *testing.T) {
+	for name, input := range testInputs(t) {
+		t.Run(name, func(t *testing.T) {
+			data := []byte(input)
+			want := scanAll(t, bytes.NewReader(data), len(data)+1)
+			for offset := 0; offset <= len(data); offset++ {
+				r := io.MultiReader(bytes.NewReader(data[:offset]), bytes.NewReader(data[offset:]))
+				if got := scanAll(t, r, len(data)+1); got != want {
+					t.Errorf("split at %d: got %+v, want %+v", offset, got, want)
+				}
+			}
+		})
+	}
+}
+
+// TestSmallBuffers makes Scan read through buffers smaller than most
+// instructions.
+func TestSmallBuffers(t *testing.T) {
+	for name, input := range testInputs(t) {
+		t.Run(name, func(t *testing.T) {
+			want := scanAll(t, strings.NewReader(input), len(input)+1)
+			for _, bufferSize := range []int{1, 2, 3, 7} {
+				if got := scanAll(t, strings.NewReader(input), bufferSize); got != want {
+					t.Errorf("buffer of %d: got %+v, want %+v", bufferSize, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestEdgeTotals(t *testing.T) {
+	tests := []struct {
+		input string
+		want  totals
+	}{
+		{"mul(2,3)don't()", totals{6, 6, false}},
+		{"mul(12,3", totals{0, 0, true}},
+		{"mul(12mul(3,4)", totals{12, 12, true}},
+		{"mul(1234,5)mul(6,7)", totals{42, 42, true}},
+		{"don't()mul(1,1)do()mul(2,2)don't()", totals{5, 4, false}},
+	}
+	for _, test := range tests {
+		if got := scanAll(t, strings.NewReader(test.input), 1); got != test.want {
+			t.Errorf("%q: got %+v, want %+v", test.input, got, test.want)
+		}
+	}
+}
+
+func TestScanRejectsEmptyBuffer(t *testing.T) {
+	for _, bufferSize := range []int{0, -1} {
+		if err := NewMachine().Scan(strings.NewReader("mul(2,3)"), bufferSize); err == nil {
+			t.Errorf("buffer of %d: want an error", bufferSize)
+		}
+	}
+}
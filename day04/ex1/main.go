@@ -2,13 +2,51 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
+	"html"
 	"os"
 	"strings"
 )
 
+type Coordinate struct {
+	row    int
+	column int
+}
+
+type Direction struct {
+	name   string
+	row    int
+	column int
+}
+
+var directions = []Direction{
+	{"N", -1, 0},
+	{"NE", -1, 1},
+	{"E", 0, 1},
+	{"SE", 1, 1},
+	{"S", 1, 0},
+	{"SW", 1, -1},
+	{"W", 0, -1},
+	{"NW", -1, -1},
+}
+
+// Match is one occurrence of a word, read from start in direction.
+type Match struct {
+	start     Coordinate
+	direction Direction
+	word      string
+}
+
 func main() {
-	file, err := os.Open("input.txt")
+	inputPath := flag.String("input", "input.txt", "word search grid")
+	wordList := flag.String("words", "XMAS", "comma separated words to find")
+	list := flag.Bool("list", false, "print every match")
+	render := flag.String("render", "", "print the grid with matched letters shown: ansi or dots")
+	heatmap := flag.String("heatmap", "", "write an HTML heat map of matches per cell to this file")
+	flag.Parse()
+
+	file, err := os.Open(*inputPath)
 	if err != nil {
 		fmt.Println("Error opening file:", err)
 		return
@@ -28,80 +66,207 @@ func main() {
 		return
 	}
 
-	var horizontalAndVertical []string
-	var diagonal []string
-	for lineNum := range len(grid) {
-		fmt.Printf("line is: %s\n", string(grid[lineNum]))
-
-		// horizontal string from this pos
-		horizontalAndVertical = append(horizontalAndVertical, string(grid[lineNum]))
+	matches := FindWords(grid, strings.Split(*wordList, ","))
+	if *list {
+		for _, match := range matches {
+			fmt.Printf("%s at row %d, column %d going %s\n", match.word, match.start.row, match.start.column, match.direction.name)
+		}
+	}
 
+	coverage := countCoverage(grid, matches)
+	switch *render {
+	case "ansi":
+		renderGrid(grid, coverage, func(r rune) string { return "\x1b[1;31m" + string(r) + "\x1b[0m" }, func(r rune) string { return string(r) })
+	case "dots":
+		renderGrid(grid, coverage, func(r rune) string { return string(r) }, func(r rune) string { return "." })
+	}
+	if *heatmap != "" {
+		if err := writeHeatmap(*heatmap, grid, coverage); err != nil {
+			fmt.Println("Error writing heat map:", err)
+		}
 	}
 
-	// add the vertical strings
-	for columnPos := range len(grid[0]) {
-		verticalString := findStringFromPos(grid, 0, columnPos, +1, 0)
+	fmt.Printf("Total found: %d\n", len(matches))
+}
 
-		horizontalAndVertical = append(horizontalAndVertical, verticalString)
+// countCoverage counts how many matches use each cell of the grid.
+func countCoverage(grid [][]rune, matches []Match) [][]int {
+	coverage := make([][]int, len(grid))
+	for row := range grid {
+		coverage[row] = make([]int, len(grid[row]))
 	}
+	for _, match := range matches {
+		c := match.start
+		for range []rune(match.word) {
+			coverage[c.row][c.column]++
+			c = step(c, match.direction, 1)
+		}
+	}
+	return coverage
+}
 
-	totalFound := 0
-	for i := range horizontalAndVertical {
-		search := horizontalAndVertical[i]
-		fmt.Printf("searchable string is: %s\n", search)
+func renderGrid(grid [][]rune, coverage [][]int, matched func(rune) string, unmatched func(rune) string) {
+	for row := range grid {
+		var line strings.Builder
+		for column, r := range grid[row] {
+			if coverage[row][column] > 0 {
+				line.WriteString(matched(r))
+			} else {
+				line.WriteString(unmatched(r))
+			}
+		}
+		fmt.Println(line.String())
+	}
+}
 
-		totalFound += findNumStrings(search, "XMAS")
-		totalFound += findNumStrings(search, "SAMX")
+// writeHeatmap shades each cell by how many matches cover it, darkest for the
+// busiest cell, with the count in the cell's tooltip.
+func writeHeatmap(path string, grid [][]rune, coverage [][]int) error {
+	most := 0
+	for _, row := range coverage {
+		for _, count := range row {
+			most = max(most, count)
+		}
 	}
 
-	for x := len(grid[0]) - 1; x >= 0; x-- {
-		// get diagonals slopping down and to right (y+1, x+1)
-		diagonal = append(diagonal, findStringFromPos(grid, 0, x, +1, +1))
+	var builder strings.Builder
+	builder.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<style>\n")
+	builder.WriteString("table { border-collapse: collapse; font-family: monospace; }\n")
+	builder.WriteString("td { width: 1.4em; height: 1.4em; text-align: center; }\n")
+	builder.WriteString("</style>\n</head>\n<body>\n<table>\n")
+	for row := range grid {
+		builder.WriteString("<tr>")
+		for column, r := range grid[row] {
+			count := coverage[row][column]
+			alpha := 0.0
+			if most > 0 {
+				alpha = float64(count) / float64(most)
+			}
+			fmt.Fprintf(&builder, `<td title="%d" style="background: rgba(220, 40, 40, %.2f)">%s</td>`,
+				count, alpha, html.EscapeString(string(r)))
+		}
+		builder.WriteString("</tr>\n")
 	}
-	for y := 1; y < len(grid); y++ {
-		// get diagonals slopping down and to right (y+1, x+1)
-		diagonal = append(diagonal, findStringFromPos(grid, y, 0, +1, +1))
+	builder.WriteString("</table>\n</body>\n</html>\n")
+
+	return os.WriteFile(path, []byte(builder.String()), 0644)
+}
+
+// FindWords finds every occurrence of every word in all 8 directions. Each
+// direction splits the grid into lines that cover every cell once, and every
+// line goes through one Aho-Corasick automaton for the whole word list, so the
+// work is linear in the size of the grid however many words there are. Rows
+// may have different lengths; a missing cell ends a line.
+func FindWords(grid [][]rune, words []string) []Match {
+	automaton := newAutomaton(words)
+
+	var matches []Match
+	for _, direction := range directions {
+		for row := range grid {
+			for column := range grid[row] {
+				start := Coordinate{row: row, column: column}
+				if inGrid(grid, step(start, direction, -1)) {
+					continue // not the first cell of a line
+				}
+
+				var line []Coordinate
+				for c := start; inGrid(grid, c); c = step(c, direction, 1) {
+					line = append(line, c)
+				}
+
+				automaton.search(grid, line, func(end int, word string) {
+					first := line[end-len([]rune(word))+1]
+					matches = append(matches, Match{start: first, direction: direction, word: word})
+				})
+			}
+		}
 	}
-	for x := len(grid[0]) - 1; x >= 0; x-- {
-		// get diagonals slopping up and to right (y-1, x+1)
-		diagonal = append(diagonal, findStringFromPos(grid, len(grid)-1, x, -1, +1))
+	return matches
+}
+
+func step(c Coordinate, direction Direction, n int) Coordinate {
+	return Coordinate{
+		row:    c.row + direction.row*n,
+		column: c.column + direction.column*n,
 	}
-	for y := len(grid[0]) - 2; y >= 0; y-- {
-		// get diagonals slopping up and to right (y-1, x+1)
-		diagonal = append(diagonal, findStringFromPos(grid, y, 0, -1, +1))
+}
+
+func inGrid(grid [][]rune, c Coordinate) bool {
+	return c.row >= 0 && c.row < len(grid) && c.column >= 0 && c.column < len(grid[c.row])
+}
+
+// automaton is an Aho-Corasick trie over the word list. Node 0 is the root.
+type automaton struct {
+	children []map[rune]int
+	fail     []int
+	words    [][]string // words ending at each node, including via fail links
+}
+
+func newAutomaton(words []string) *automaton {
+	a := &automaton{
+		children: []map[rune]int{{}},
+		fail:     []int{0},
+		words:    [][]string{nil},
 	}
 
-	for i := range diagonal {
-		search := diagonal[i]
-		fmt.Printf("diagonal string is: %s\n", search)
+	added := make(map[string]bool)
+	for _, word := range words {
+		if word == "" || added[word] {
+			continue
+		}
+		added[word] = true
+		node := 0
+		for _, r := range word {
+			next, exists := a.children[node][r]
+			if !exists {
+				next = len(a.children)
+				a.children = append(a.children, map[rune]int{})
+				a.fail = append(a.fail, 0)
+				a.words = append(a.words, nil)
+				a.children[node][r] = next
+			}
+			node = next
+		}
+		a.words[node] = append(a.words[node], word)
+	}
 
-		totalFound += findNumStrings(search, "XMAS")
-		totalFound += findNumStrings(search, "SAMX")
+	// breadth first so every fail link points at an already finished node
+	queue := []int{}
+	for _, child := range a.children[0] {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for r, child := range a.children[node] {
+			a.fail[child] = a.next(a.fail[node], r)
+			a.words[child] = append(a.words[child], a.words[a.fail[child]]...)
+			queue = append(queue, child)
+		}
 	}
 
-	fmt.Printf("Total found: %d\n", totalFound)
+	return a
 }
 
-func findNumStrings(text, substring string) int {
-	count := 0
-	for i := 0; ; {
-		index := strings.Index(text[i:], substring)
-		if index == -1 {
-			break
+// next follows fail links until some node can take r.
+func (a *automaton) next(node int, r rune) int {
+	for {
+		if child, exists := a.children[node][r]; exists {
+			return child
+		}
+		if node == 0 {
+			return 0
 		}
-		count++
-		i += index + 1
+		node = a.fail[node]
 	}
-	return count
 }
 
-func findStringFromPos(grid [][]rune, y int, x int, yDirection int, xDirection int) string {
-	fmt.Printf("y=%d, len(grid)-1=%d, x=%d, len(grid[0])-1=%d\n", y, len(grid)-1, x, len(grid[0])-1)
-	if y > len(grid)-1 || x > len(grid[0])-1 ||
-		y < 0 || x < 0 {
-		// at this point we are out of bounds
-		return ""
+func (a *automaton) search(grid [][]rune, line []Coordinate, found func(end int, word string)) {
+	node := 0
+	for i, c := range line {
+		node = a.next(node, grid[c.row][c.column])
+		for _, word := range a.words[node] {
+			found(i, word)
+		}
 	}
-
-	return string(grid[y][x]) + findStringFromPos(grid, y+yDirection, x+xDirection, yDirection, xDirection)
 }
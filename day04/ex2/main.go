@@ -2,12 +2,58 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"os"
+	"strings"
 )
 
+type Coordinate struct {
+	row    int
+	column int
+}
+
+// Orientation maps a cell of an h by w pattern to where it lands once the
+// pattern is turned. swaps says whether the result is w by h.
+type Orientation struct {
+	name  string
+	swaps bool
+	place func(row, column, h, w int) (int, int)
+}
+
+var orientations = []Orientation{
+	{"identity", false, func(r, c, h, w int) (int, int) { return r, c }},
+	{"rot90", true, func(r, c, h, w int) (int, int) { return c, h - 1 - r }},
+	{"rot180", false, func(r, c, h, w int) (int, int) { return h - 1 - r, w - 1 - c }},
+	{"rot270", true, func(r, c, h, w int) (int, int) { return w - 1 - c, r }},
+	{"flipH", false, func(r, c, h, w int) (int, int) { return r, w - 1 - c }},
+	{"flipV", false, func(r, c, h, w int) (int, int) { return h - 1 - r, c }},
+	{"transpose", true, func(r, c, h, w int) (int, int) { return c, r }},
+	{"antitranspose", true, func(r, c, h, w int) (int, int) { return w - 1 - c, h - 1 - r }},
+}
+
+const WILDCARD = '.'
+
+// Template is a pattern turned to one orientation.
+type Template struct {
+	orientation string
+	cells       [][]rune
+}
+
+// Match is a template found with its top left corner at location.
+type Match struct {
+	location    Coordinate
+	orientation string
+}
+
 func main() {
-	file, err := os.Open("input.txt")
+	inputPath := flag.String("input", "input.txt", "word search grid")
+	pattern := flag.String("pattern", "M.S/.A./M.S", "rows separated by /, with . matching anything")
+	allowed := flag.String("orientations", "all", "comma separated orientations to try, or all")
+	list := flag.Bool("list", false, "print every match")
+	flag.Parse()
+
+	file, err := os.Open(*inputPath)
 	if err != nil {
 		fmt.Println("Error opening file:", err)
 		return
@@ -22,21 +68,138 @@ func main() {
 		grid = append(grid, line)
 	}
 
-	totalFound := 0
-	for y := 1; y < len(grid)-1; y++ {
-		for x := 1; x < len(grid[y])-1; x++ {
-			if grid[y][x] == 'A' { // keying off the middle of the pattern and will look at neighbors from here
-				if isMas(grid[y+1][x-1], grid[y][x], grid[y-1][x+1]) &&
-					isMas(grid[y-1][x-1], grid[y][x], grid[y+1][x+1]) {
-					totalFound++
-				}
+	chosen, err := chooseOrientations(*allowed)
+	if err != nil {
+		fmt.Println("Error choosing orientations:", err)
+		return
+	}
+
+	templates := buildTemplates(parsePattern(*pattern), chosen)
+	matches := FindTemplates(grid, templates)
+	if *list {
+		for _, match := range matches {
+			fmt.Printf("row %d, column %d as %s\n", match.location.row, match.location.column, match.orientation)
+		}
+	}
+
+	fmt.Printf("Total found: %d\n", len(matches))
+}
+
+// chooseOrientations looks up a comma separated list of orientation names,
+// keeping them in table order. "all" chooses every one.
+func chooseOrientations(list string) ([]Orientation, error) {
+	if list == "all" {
+		return orientations, nil
+	}
+
+	known := make(map[string]bool)
+	for _, o := range orientations {
+		known[o.name] = true
+	}
+	wanted := make(map[string]bool)
+	for _, name := range strings.Split(list, ",") {
+		if !known[name] {
+			return nil, fmt.Errorf("unknown orientation %q", name)
+		}
+		wanted[name] = true
+	}
+
+	var chosen []Orientation
+	for _, o := range orientations {
+		if wanted[o.name] {
+			chosen = append(chosen, o)
+		}
+	}
+	return chosen, nil
+}
+
+// parsePattern reads rows separated by '/', padding short rows with
+// wildcards so the pattern is rectangular.
+func parsePattern(pattern string) [][]rune {
+	var cells [][]rune
+	width := 0
+	for _, row := range strings.Split(pattern, "/") {
+		cells = append(cells, []rune(row))
+		width = max(width, len(cells[len(cells)-1]))
+	}
+	for i := range cells {
+		for len(cells[i]) < width {
+			cells[i] = append(cells[i], WILDCARD)
+		}
+	}
+	return cells
+}
+
+// buildTemplates turns the pattern every chosen way. Orientations that give
+// the same shape (the X-MAS is symmetric, for one) only keep the first, so a
+// spot in the grid isn't counted twice for it.
+func buildTemplates(pattern [][]rune, chosen []Orientation) []Template {
+	h := len(pattern)
+	w := len(pattern[0])
+
+	var templates []Template
+	seen := make(map[string]bool)
+	for _, o := range chosen {
+		rows, columns := h, w
+		if o.swaps {
+			rows, columns = w, h
+		}
+		cells := make([][]rune, rows)
+		for r := range cells {
+			cells[r] = make([]rune, columns)
+		}
+		for r := range pattern {
+			for c := range pattern[r] {
+				newR, newC := o.place(r, c, h, w)
+				cells[newR][newC] = pattern[r][c]
 			}
 		}
+
+		key := templateKey(cells)
+		if !seen[key] {
+			seen[key] = true
+			templates = append(templates, Template{orientation: o.name, cells: cells})
+		}
 	}
+	return templates
+}
 
-	fmt.Printf("Total found: %d\n", totalFound)
+func templateKey(cells [][]rune) string {
+	rows := make([]string, len(cells))
+	for i, row := range cells {
+		rows[i] = string(row)
+	}
+	return strings.Join(rows, "/")
 }
 
-func isMas(a, b, c rune) bool {
-	return (a == 'M' && b == 'A' && c == 'S') || (a == 'S' && b == 'A' && c == 'M')
+// FindTemplates tries every template with its corner on every cell of the
+// grid. Rows of the grid may have different lengths.
+func FindTemplates(grid [][]rune, templates []Template) []Match {
+	var matches []Match
+	for row := range grid {
+		for column := range grid[row] {
+			corner := Coordinate{row: row, column: column}
+			for _, template := range templates {
+				if fits(grid, template, corner) {
+					matches = append(matches, Match{location: corner, orientation: template.orientation})
+				}
+			}
+		}
+	}
+	return matches
+}
+
+func fits(grid [][]rune, template Template, corner Coordinate) bool {
+	for r, templateRow := range template.cells {
+		for c, want := range templateRow {
+			if want == WILDCARD {
+				continue
+			}
+			y, x := corner.row+r, corner.column+c
+			if y >= len(grid) || x >= len(grid[y]) || grid[y][x] != want {
+				return false
+			}
+		}
+	}
+	return true
 }
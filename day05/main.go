@@ -2,18 +2,22 @@ package main
 
 import (
 	_ "embed"
+	"flag"
 	"fmt"
-	"reflect"
-	"slices"
 	"sort"
 	"strconv"
 	"strings"
+
+	"day5/pagerules"
 )
 
 //go:embed input.txt
 var embeddedFile string
 
 func main() {
+	report := flag.Bool("report", false, "explain which rules every bad update breaks")
+	flag.Parse()
+
 	// Split the embedded content into lines
 	lines := strings.Split(embeddedFile, "\n")
 
@@ -38,26 +42,46 @@ func main() {
 		}
 	}
 
-	requiredBeforeMap := make(map[int][]int)
+	rules := pagerules.New()
 	for _, orderingRule := range orderingRules {
 		page, requiredBefore := readOrderingRule(orderingRule)
-		requiredBeforeMap[page] = append(requiredBeforeMap[page], requiredBefore)
+		rules.Add(requiredBefore, page)
 	}
 
 	middleSum := 0
 	badMiddleSum := 0
-	for _, pageNumbers := range pagesToProduce {
+	timesBroken := make(map[[2]int]int)
+	for updateNum, pageNumbers := range pagesToProduce {
 		pages := strings.Split(pageNumbers, ",")
 		pageNums := readPages(pages)
-		orderedPageNums := reorderSlice(pageNums, requiredBeforeMap)
 
-		middle := getMiddleValue(orderedPageNums)
+		violations := rules.Validate(pageNums)
+		if len(violations) == 0 {
+			middleSum += getMiddleValue(pageNums)
+			continue
+		}
 
-		if reflect.DeepEqual(pageNums, orderedPageNums) {
-			middleSum += middle
-		} else {
-			badMiddleSum += middle
+		orderedPageNums, err := rules.Fix(pageNums)
+		if err != nil {
+			fmt.Printf("Skipping update %d (%s): %v\n", updateNum+1, pageNumbers, err)
+			continue
 		}
+		badMiddleSum += getMiddleValue(orderedPageNums)
+
+		for _, v := range violations {
+			timesBroken[[2]int{v.Before, v.After}]++
+		}
+		if *report {
+			fmt.Printf("Update %d (%s) needs %d adjacent swaps:\n", updateNum+1, pageNumbers, countSwaps(pageNums, orderedPageNums))
+			for _, v := range violations {
+				fmt.Printf("    breaks %d|%d: %d is at position %d, %d at position %d\n",
+					v.Before, v.After, v.Before, v.BeforePosition, v.After, v.AfterPosition)
+			}
+		}
+	}
+
+	if *report && len(timesBroken) > 0 {
+		printMostBroken(timesBroken)
 	}
 
 	fmt.Printf("Sum of good middle values is: %d\n", middleSum)
@@ -81,29 +105,44 @@ func readPages(pages []string) []int {
 	return pagesInt
 }
 
-func reorderSlice(slice []int, requiredBeforeMap map[int][]int) []int {
-	// Create a copy of the slice to avoid modifying the original
-	result := make([]int, len(slice))
-	copy(result, slice)
-
-	// Use the sort.Slice function with the custom comparison logic
-	sort.Slice(result, func(i, j int) bool {
-		return isRequiredBefore(result[i], result[j], slice, requiredBeforeMap)
-	})
+// countSwaps is the fewest swaps of neighbouring pages that turn pages into
+// ordered, which is the number of pairs the two disagree on.
+func countSwaps(pages []int, ordered []int) int {
+	rank := make(map[int]int)
+	for i, page := range ordered {
+		rank[page] = i
+	}
 
-	return result
+	swaps := 0
+	for i := range pages {
+		for j := i + 1; j < len(pages); j++ {
+			if rank[pages[i]] > rank[pages[j]] {
+				swaps++
+			}
+		}
+	}
+	return swaps
 }
 
-func isRequiredBefore(page int, otherPage int, allPages []int, requiredBeforeMap map[int][]int) bool {
-	for _, requiredPage := range requiredBeforeMap[otherPage] {
-		if slices.Contains(allPages, requiredPage) {
-			// this only matters if the requiredPage is in this input
-			if page == requiredPage {
-				return true
-			}
+func printMostBroken(timesBroken map[[2]int]int) {
+	var rules [][2]int
+	for rule := range timesBroken {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if timesBroken[rules[i]] != timesBroken[rules[j]] {
+			return timesBroken[rules[i]] > timesBroken[rules[j]]
+		}
+		if rules[i][0] != rules[j][0] {
+			return rules[i][0] < rules[j][0]
 		}
+		return rules[i][1] < rules[j][1]
+	})
+
+	fmt.Println("Rules broken most often:")
+	for _, rule := range rules {
+		fmt.Printf("    %d|%d broken in %d updates\n", rule[0], rule[1], timesBroken[rule])
 	}
-	return false
 }
 
 func getMiddleValue(pages []int) int {
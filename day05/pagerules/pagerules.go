@@ -0,0 +1,176 @@
+// Package pagerules holds the page ordering rules for the safety manual
+// updates, so they can be queried and edited without reparsing the rule text.
+package pagerules
+
+import "fmt"
+
+// PageRules is a set of X|Y rules, each saying page X must be printed before
+// page Y when both are in an update. Alongside the rules it keeps which pages
+// every page reaches through chains of rules, updated on every Add and Remove,
+// so MustPrecede is a lookup.
+type PageRules struct {
+	after     map[int]map[int]bool // after[x][y] for each rule x|y
+	reaches   map[int]map[int]bool // reaches[x][y] if a chain of rules leads from x to y
+	reachedBy map[int]map[int]bool // reachedBy[y][x] whenever reaches[x][y]
+}
+
+// Violation is an X|Y rule an update breaks by printing Y at an earlier
+// position than X. Positions count from 0.
+type Violation struct {
+	Before         int
+	After          int
+	BeforePosition int
+	AfterPosition  int
+}
+
+func New() *PageRules {
+	return &PageRules{
+		after:     make(map[int]map[int]bool),
+		reaches:   make(map[int]map[int]bool),
+		reachedBy: make(map[int]map[int]bool),
+	}
+}
+
+// Add records the rule a|b. Everything that reaches a, and a itself, now also
+// reaches b and everything b reaches.
+func (r *PageRules) Add(a, b int) {
+	if r.after[a][b] {
+		return
+	}
+	addTo(r.after, a, b)
+
+	sources := []int{a}
+	for x := range r.reachedBy[a] {
+		sources = append(sources, x)
+	}
+	targets := []int{b}
+	for y := range r.reaches[b] {
+		targets = append(targets, y)
+	}
+	for _, x := range sources {
+		for _, y := range targets {
+			addTo(r.reaches, x, y)
+			addTo(r.reachedBy, y, x)
+		}
+	}
+}
+
+// Remove drops the rule a|b. Only pages that reached a can lose anything, so
+// just their reachable sets are walked again.
+func (r *PageRules) Remove(a, b int) {
+	if !r.after[a][b] {
+		return
+	}
+	delete(r.after[a], b)
+
+	sources := []int{a}
+	for x := range r.reachedBy[a] {
+		sources = append(sources, x)
+	}
+	for _, x := range sources {
+		reachable := r.walk(x)
+		for y := range r.reaches[x] {
+			if !reachable[y] {
+				delete(r.reachedBy[y], x)
+			}
+		}
+		r.reaches[x] = reachable
+	}
+}
+
+// walk finds every page a chain of rules leads to from start.
+func (r *PageRules) walk(start int) map[int]bool {
+	reachable := make(map[int]bool)
+	queue := []int{start}
+	for len(queue) > 0 {
+		page := queue[0]
+		queue = queue[1:]
+		for next := range r.after[page] {
+			if !reachable[next] {
+				reachable[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return reachable
+}
+
+// MustPrecede reports whether some chain of rules puts a before b.
+func (r *PageRules) MustPrecede(a, b int) bool {
+	return r.reaches[a][b]
+}
+
+// Validate lists every rule the update breaks. Like the puzzle, it only looks
+// at rules whose two pages are both in the update.
+func (r *PageRules) Validate(update []int) []Violation {
+	var violations []Violation
+	for i, page := range update {
+		for j := i + 1; j < len(update); j++ {
+			if r.after[update[j]][page] {
+				violations = append(violations, Violation{Before: update[j], After: page, BeforePosition: j, AfterPosition: i})
+			}
+		}
+	}
+	return violations
+}
+
+// Fix puts the pages in the order the rules require, using Kahn's algorithm
+// over only the rules whose pages are both in this update. It fails if those
+// rules have a cycle, or if they leave more than one valid order, since then
+// the middle page isn't well defined.
+func (r *PageRules) Fix(update []int) ([]int, error) {
+	requiredAfter := make(map[int][]int)
+	numRequiredBefore := make(map[int]int)
+	for _, page := range update {
+		for _, other := range update {
+			if r.after[other][page] {
+				requiredAfter[other] = append(requiredAfter[other], page)
+				numRequiredBefore[page]++
+			}
+		}
+	}
+
+	// keep the ready pages in input order so the result is deterministic
+	var ready []int
+	for _, page := range update {
+		if numRequiredBefore[page] == 0 {
+			ready = append(ready, page)
+		}
+	}
+
+	var result []int
+	for len(ready) > 0 {
+		if len(ready) > 1 {
+			return nil, fmt.Errorf("ambiguous order, no rule decides between pages %d and %d", ready[0], ready[1])
+		}
+		page := ready[0]
+		ready = ready[1:]
+		result = append(result, page)
+
+		for _, next := range requiredAfter[page] {
+			numRequiredBefore[next]--
+			if numRequiredBefore[next] == 0 {
+				ready = append(ready, next)
+			}
+		}
+	}
+
+	if len(result) < len(update) {
+		var stuck []int
+		for _, page := range update {
+			if numRequiredBefore[page] > 0 {
+				stuck = append(stuck, page)
+			}
+		}
+		return nil, fmt.Errorf("rules form a cycle among pages %v", stuck)
+	}
+
+	return result, nil
+}
+
+func addTo(m map[int]map[int]bool, x, y int) {
+	if m[x] == nil {
+		m[x] = make(map[int]bool)
+	}
+	m[x][y] = true
+}
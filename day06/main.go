@@ -2,8 +2,15 @@ package main
 
 import (
 	_ "embed"
+	"encoding/csv"
+	"flag"
 	"fmt"
+	"math/bits"
+	"os"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync"
 )
 
 //go:embed input.txt
@@ -36,15 +43,71 @@ type State struct {
 	direction rune
 }
 
-var phantomDistinctLocationsVisited = make(map[State]bool)
-var distinctLocationsVisited = make(map[Coordinate]struct{})
-var testedObstacleLocations = make(map[Coordinate]bool)
-var numObstacles = 0
+// Outcome is how a walk ends: the guard leaves the grid, or comes back to a
+// state they've been in and walks in circles forever.
+type Outcome int
+
+const (
+	EXITED Outcome = iota
+	LOOPED
+)
+
+// Walk is everything one walk of the guard produced. path is every state in
+// order and visited marks the cells stood on, indexed by cellIndex.
+type Walk struct {
+	path    []State
+	visited Bitset
+	outcome Outcome
+}
+
+type Bitset []uint64
+
+func newBitset(n int) Bitset {
+	return make(Bitset, (n+63)/64)
+}
+
+func (b Bitset) set(i int)      { b[i/64] |= 1 << (i % 64) }
+func (b Bitset) unset(i int)    { b[i/64] &^= 1 << (i % 64) }
+func (b Bitset) has(i int) bool { return b[i/64]&(1<<(i%64)) != 0 }
+
+func (b Bitset) count() int {
+	total := 0
+	for _, word := range b {
+		total += bits.OnesCount64(word)
+	}
+	return total
+}
+
+// Candidate is an obstacle to try, and where the guard stands when they
+// first bump into it.
+type Candidate struct {
+	from     State
+	obstacle Coordinate
+}
+
+// LoopingObstacle is an obstacle that traps the guard, and how many steps one
+// lap of the loop it causes takes.
+type LoopingObstacle struct {
+	position   Coordinate
+	loopLength int
+}
+
+// jumpTable[d][c] is where a guard on cell c facing directions[d] stops: the
+// last cell before the next obstacle, or -1 if they walk off the grid first.
+// Phantom walks use it to go straight from one turn to the next.
+var jumpTable [][]int
+
+// noObstacle is the extra obstacle for walks on the grid as it is.
+var noObstacle = Coordinate{row: -1, column: -1}
 
 const OBSTACLE = '#'
 const CLEAR = '.'
 
 func main() {
+	workers := flag.Int("workers", runtime.NumCPU(), "goroutines checking obstacle candidates")
+	render := flag.Bool("render", false, "print the guard's route with the looping obstacles marked O")
+	csvPath := flag.String("csv", "", "write the looping obstacles and their loop lengths to this CSV file")
+	flag.Parse()
 
 	lines := strings.Split(embeddedFile, "\n")
 	for _, line := range lines {
@@ -54,62 +117,258 @@ func main() {
 	}
 
 	// find the guard and which direction they are facing to get started
-	foundGuard := false
-	var guardPosition Coordinate
-	var guardDirection rune
+	var guard State
 	for row := range grid {
-		if !foundGuard {
-			for column := range grid[row] {
-				if _, exists := directionMap[grid[row][column]]; exists {
-					guardDirection = grid[row][column]
-					guardPosition = Coordinate{row: row, column: column}
-				}
+		for column := range grid[row] {
+			if _, exists := directionMap[grid[row][column]]; exists {
+				guard = State{position: Coordinate{row: row, column: column}, direction: grid[row][column]}
 			}
 		}
 	}
 
-	walkItOut(guardDirection, guardPosition, false)
+	buildJumpTable()
+	walk := walkItOut(guard, newStateBitset())
+	loopingObstacles := findLoopingObstacles(findCandidates(walk), *workers)
 
-	fmt.Printf("Number of distinct locations: %d\n", len(distinctLocationsVisited))
-	fmt.Printf("Number of possible obstacle locations: %d\n", numObstacles)
+	if *render {
+		renderRoute(walk, loopingObstacles)
+	}
+	if *csvPath != "" {
+		if err := writeLoopingObstacles(*csvPath, loopingObstacles); err != nil {
+			fmt.Printf("Error writing CSV: %v\n", err)
+		}
+	}
+
+	if walk.outcome == LOOPED {
+		fmt.Println("The guard never leaves, they walk in a loop.")
+	}
+	fmt.Printf("Number of distinct locations: %d\n", walk.visited.count())
+	fmt.Printf("Number of possible obstacle locations: %d\n", len(loopingObstacles))
 }
 
-func walkItOut(guardDirection rune, guardPosition Coordinate, isPhantomRealm bool) bool {
-	currentState := State{position: guardPosition, direction: guardDirection}
-	if isPhantomRealm {
-		if phantomDistinctLocationsVisited[currentState] {
-			return true // Found a loop
+// findCandidates puts an obstacle in front of each step the first time the
+// guard gets there. The guard would bump into it from where they stand, so
+// that's where its phantom walk starts.
+func findCandidates(walk Walk) []Candidate {
+	var candidates []Candidate
+	tested := newBitset(len(grid) * len(grid[0]))
+	for i := 0; i+1 < len(walk.path); i++ {
+		state, nextPosition := walk.path[i], walk.path[i+1].position
+		if nextPosition == state.position || tested.has(cellIndex(nextPosition)) {
+			continue // turning on the spot, or already tried here
+		}
+		tested.set(cellIndex(nextPosition))
+		if CLEAR == grid[nextPosition.row][nextPosition.column] {
+			candidates = append(candidates, Candidate{from: state, obstacle: nextPosition})
 		}
-		phantomDistinctLocationsVisited[currentState] = true
-	} else {
-		phantomDistinctLocationsVisited = make(map[State]bool)
-		distinctLocationsVisited[guardPosition] = struct{}{}
 	}
+	return candidates
+}
 
-	nextPosition := addCoordinates(guardPosition, coordinateMap[guardDirection])
+// findLoopingObstacles checks the candidates on a pool of workers. The grid
+// is only read, each candidate brings its own extra obstacle, and each worker
+// has its own seen states, so the result is the same as checking them in turn
+// and comes back in candidate order.
+func findLoopingObstacles(candidates []Candidate, workers int) []LoopingObstacle {
+	jobs := make(chan int)
+	loopLengths := make([]int, len(candidates))
+	looped := make([]bool, len(candidates))
 
-	if isOutOfBounds(nextPosition) {
-		return false // found an exit
+	var wg sync.WaitGroup
+	for range max(workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			seen := newStateBitset()
+			for i := range jobs {
+				loopLengths[i], looped[i] = loops(candidates[i].from, candidates[i].obstacle, seen)
+			}
+		}()
+	}
+
+	for i := range candidates {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	var loopingObstacles []LoopingObstacle
+	for i, loopLength := range loopLengths {
+		if looped[i] {
+			loopingObstacles = append(loopingObstacles, LoopingObstacle{position: candidates[i].obstacle, loopLength: loopLength})
+		}
+	}
+	return loopingObstacles
+}
+
+func newStateBitset() Bitset {
+	return newBitset(len(grid) * len(grid[0]) * len(directions))
+}
+
+// walkItOut follows the guard from start until they leave the grid or repeat
+// a state. seen must be empty and is left that way.
+func walkItOut(start State, seen Bitset) Walk {
+	walk := Walk{visited: newBitset(len(grid) * len(grid[0])), outcome: EXITED}
+	state, inBounds := start, true
+	for inBounds {
+		if seen.has(stateIndex(state)) {
+			walk.outcome = LOOPED
+			break
+		}
+		seen.set(stateIndex(state))
+		walk.path = append(walk.path, state)
+		walk.visited.set(cellIndex(state.position))
+
+		state, inBounds = step(state, noObstacle)
+	}
+
+	for _, state := range walk.path {
+		seen.unset(stateIndex(state))
+	}
+	return walk
+}
+
+// loops follows the guard from start with one extra obstacle and reports
+// whether they get stuck, and how many steps the loop takes. A guard boxed in
+// so they only turn on the spot is stuck in a loop of 0 steps. It
+// jumps from turn to turn with jumpTable, cutting a jump short where the extra
+// obstacle is in the way, and only remembers the states at the turns, which
+// is enough to spot a loop.
+func loops(start State, obstacle Coordinate, seen Bitset) (int, bool) {
+	var touched []int
+	var stepsTaken []int // steps walked when each touched state was reached
+	loopLength := 0
+	looped := false
+	steps := 0
+	position := cellIndex(start.position)
+	direction := directionMap[start.direction]
+	for {
+		stop := jump(position, direction, obstacle)
+		if stop == -1 {
+			break // found an exit
+		}
+		steps += cellDistance(position, stop)
+		i := stop*len(directions) + direction
+		if seen.has(i) {
+			looped = true
+			for j := range touched {
+				if touched[j] == i {
+					loopLength = steps - stepsTaken[j]
+				}
+			}
+			break
+		}
+		seen.set(i)
+		touched = append(touched, i)
+		stepsTaken = append(stepsTaken, steps)
+
+		position = stop
+		direction = (direction + 1) % len(directions)
+	}
+
+	for _, i := range touched {
+		seen.unset(i)
 	}
+	return loopLength, looped
+}
+
+func cellDistance(from, to int) int {
+	columns := len(grid[0])
+	return absInt(to/columns-from/columns) + absInt(to%columns-from%columns)
+}
 
-	if OBSTACLE == grid[nextPosition.row][nextPosition.column] {
-		// turn right but stay here, recursion takes care of it
-		guardDirection = turnRight(guardDirection)
-		return walkItOut(guardDirection, guardPosition, isPhantomRealm)
+// jump looks up where the guard stops from position, patching in the extra
+// obstacle if it stands between them and the stop the table knows about.
+func jump(position int, direction int, obstacle Coordinate) int {
+	stop := jumpTable[direction][position]
 
-	} else if !isPhantomRealm && CLEAR == grid[nextPosition.row][nextPosition.column] && !testedObstacleLocations[nextPosition] {
-		// if we haven't already working in the phantom realm,
-		//    we'll put an OBSTACLE right in front of us and see if there is a loop
-		testedObstacleLocations[nextPosition] = true
+	columns := len(grid[0])
+	from := Coordinate{row: position / columns, column: position % columns}
+	delta := coordinateMap[directions[direction]]
+	var distance int // how many steps ahead the extra obstacle is
+	switch {
+	case delta.row != 0 && obstacle.column == from.column:
+		distance = (obstacle.row - from.row) * delta.row
+	case delta.column != 0 && obstacle.row == from.row:
+		distance = (obstacle.column - from.column) * delta.column
+	}
+	if distance <= 0 {
+		return stop // not in the way
+	}
 
-		grid[nextPosition.row][nextPosition.column] = OBSTACLE
-		if walkItOut(guardDirection, guardPosition, true) {
-			numObstacles++
+	if stop != -1 {
+		to := Coordinate{row: stop / columns, column: stop % columns}
+		if distance > absInt(to.row-from.row)+absInt(to.column-from.column) {
+			return stop // behind the obstacle we'd hit anyway
 		}
-		grid[nextPosition.row][nextPosition.column] = CLEAR
 	}
+	return cellIndex(Coordinate{
+		row:    from.row + delta.row*(distance-1),
+		column: from.column + delta.column*(distance-1),
+	})
+}
 
-	return walkItOut(guardDirection, nextPosition, isPhantomRealm)
+// buildJumpTable fills jumpTable, visiting the cells for each direction in
+// the order that has the next cell along already done.
+func buildJumpTable() {
+	rows, columns := len(grid), len(grid[0])
+	jumpTable = make([][]int, len(directions))
+	for d, direction := range directions {
+		delta := coordinateMap[direction]
+		jumpTable[d] = make([]int, rows*columns)
+		for r := range rows {
+			row := r
+			if delta.row > 0 {
+				row = rows - 1 - r
+			}
+			for c := range columns {
+				column := c
+				if delta.column > 0 {
+					column = columns - 1 - c
+				}
+
+				here := Coordinate{row: row, column: column}
+				next := addCoordinates(here, delta)
+				switch {
+				case isOutOfBounds(next):
+					jumpTable[d][cellIndex(here)] = -1
+				case OBSTACLE == grid[next.row][next.column]:
+					jumpTable[d][cellIndex(here)] = cellIndex(here)
+				default:
+					jumpTable[d][cellIndex(here)] = jumpTable[d][cellIndex(next)]
+				}
+			}
+		}
+	}
+}
+
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// step moves the guard forward, or turns them right if something (the grid's
+// own obstacles or the extra one) is in the way. It reports false once they
+// walk off the grid.
+func step(state State, obstacle Coordinate) (State, bool) {
+	nextPosition := addCoordinates(state.position, coordinateMap[state.direction])
+	if isOutOfBounds(nextPosition) {
+		return state, false // found an exit
+	}
+	if nextPosition == obstacle || OBSTACLE == grid[nextPosition.row][nextPosition.column] {
+		return State{position: state.position, direction: turnRight(state.direction)}, true
+	}
+	return State{position: nextPosition, direction: state.direction}, true
+}
+
+func cellIndex(c Coordinate) int {
+	return c.row*len(grid[0]) + c.column
+}
+
+func stateIndex(s State) int {
+	return cellIndex(s.position)*len(directions) + directionMap[s.direction]
 }
 
 func addCoordinates(c1, c2 Coordinate) Coordinate {
@@ -127,11 +386,59 @@ func turnRight(guardDirection rune) rune {
 	return directions[(directionMap[guardDirection]+1)%4]
 }
 
-func containsRune(r rune, slice []rune) bool {
-	for _, item := range slice {
-		if item == r {
-			return true
+// renderRoute draws the map the way the puzzle does: | and - where the guard
+// walked up and down or across, + where they did both or turned, and O for
+// every obstacle that would trap them.
+func renderRoute(walk Walk, loopingObstacles []LoopingObstacle) {
+	route := make([][]rune, len(grid))
+	for row := range grid {
+		route[row] = append([]rune{}, grid[row]...)
+	}
+
+	for _, state := range walk.path[1:] {
+		c := state.position
+		mark := '|'
+		if state.direction == '<' || state.direction == '>' {
+			mark = '-'
 		}
+		switch route[c.row][c.column] {
+		case CLEAR:
+			route[c.row][c.column] = mark
+		case '|', '-':
+			if route[c.row][c.column] != mark {
+				route[c.row][c.column] = '+'
+			}
+		}
+	}
+	for _, loopingObstacle := range loopingObstacles {
+		c := loopingObstacle.position
+		route[c.row][c.column] = 'O'
+	}
+
+	for _, line := range route {
+		fmt.Println(string(line))
+	}
+}
+
+func writeLoopingObstacles(path string, loopingObstacles []LoopingObstacle) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"row", "column", "loop_length"})
+	for _, loopingObstacle := range loopingObstacles {
+		writer.Write([]string{
+			strconv.Itoa(loopingObstacle.position.row),
+			strconv.Itoa(loopingObstacle.position.column),
+			strconv.Itoa(loopingObstacle.loopLength),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
 	}
-	return false
+	return file.Close()
 }
@@ -2,46 +2,508 @@ package main
 
 import (
 	_ "embed"
+	"flag"
 	"fmt"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 //go:embed input.txt
 var embeddedFile string
 
+// Operator combines the value so far with the next operand. Apply reports
+// false when the operator can't take operand b, whatever the value so far.
+// Undo goes the other way, finding the value so far that gives target with
+// operand b; it is nil when that value isn't unique. Absorbs reports when
+// operand b gives the same result whatever the value so far, like times
+// zero, and what that result is; Undo isn't called for such operands.
+// Monotone operators never give less than the value so far when it is
+// non-negative and the next operand is positive, which lets the search give
+// up as soon as it passes the test value.
+type Operator struct {
+	Symbol   string
+	Apply    func(a, b int) (int, bool)
+	Undo     func(target, b int) (int, bool)
+	Absorbs  func(b int) (int, bool)
+	Monotone bool
+}
+
+// operatorRegistry is every operator a run can choose from with -operators.
+var operatorRegistry = []Operator{
+	{
+		Symbol:   "+",
+		Monotone: true,
+		Apply:    func(a, b int) (int, bool) { return a + b, true },
+		Undo:     func(target, b int) (int, bool) { return target - b, true },
+	},
+	{
+		Symbol:   "*",
+		Monotone: true,
+		Apply:    func(a, b int) (int, bool) { return a * b, true },
+		Undo:     func(target, b int) (int, bool) { return target / b, target%b == 0 },
+		Absorbs:  func(b int) (int, bool) { return 0, b == 0 },
+	},
+	{
+		Symbol:   "||",
+		Monotone: true,
+		Apply: func(a, b int) (int, bool) {
+			if b < 0 {
+				return 0, false
+			}
+			return concatInts(a, b), true
+		},
+		Undo: func(target, b int) (int, bool) {
+			if b < 0 {
+				return 0, false
+			}
+			shift := digitShift(b)
+			if target < 0 {
+				// -0 isn't a thing, so a negative prefix can't be zero
+				return target / shift, -target%shift == b && target/shift != 0
+			}
+			return target / shift, target%shift == b
+		},
+	},
+	{
+		Symbol: "-",
+		Apply:  func(a, b int) (int, bool) { return a - b, true },
+		Undo:   func(target, b int) (int, bool) { return target + b, true },
+	},
+	{
+		Symbol: "/",
+		Apply: func(a, b int) (int, bool) {
+			if b == 0 {
+				return 0, false
+			}
+			return a / b, true
+		},
+	},
+	{
+		Symbol: "^",
+		Apply:  func(a, b int) (int, bool) { return a ^ b, true },
+		Undo:   func(target, b int) (int, bool) { return target ^ b, true },
+	},
+}
+
+// Solution is the ways of placing operators between an equation's operands
+// that evaluate, left to right, to its test value. Witnesses holds up to the
+// number asked for and Count covers them all, except that a Solver asked for
+// no witnesses stops at the first way it finds and leaves Count at 1.
+type Solution struct {
+	Count     int
+	Witnesses [][]*Operator
+}
+
 func main() {
-	calibrationResult := 0
+	operatorList := flag.String("operators", "+,*,||", "comma separated operators to try: "+registeredSymbols())
+	witnesses := flag.String("witnesses", "", "print one or all of the expressions that satisfy each equation")
+	workers := flag.Int("workers", runtime.NumCPU(), "goroutines solving equations")
+	flag.Parse()
+
+	operators, err := chooseOperators(*operatorList)
+	if err != nil {
+		fmt.Printf("Error choosing operators: %v\n", err)
+		return
+	}
+
+	maxWitnesses := 0
+	switch *witnesses {
+	case "":
+	case "one":
+		maxWitnesses = 1
+	case "all":
+		maxWitnesses = -1
+	default:
+		fmt.Printf("Unknown witnesses option: %s\n", *witnesses)
+		return
+	}
 
 	lines := strings.Split(embeddedFile, "\n")
 	lines = lines[:len(lines)-1]
+	var testValues []int
+	var operandLists [][]int
 	for _, line := range lines {
 		testValue, operands := parseLine(line)
-		if canProduceTestValue(testValue, operands) {
-			calibrationResult += testValue
+		testValues = append(testValues, testValue)
+		operandLists = append(operandLists, operands)
+	}
+
+	solutions := solveAll(testValues, operandLists, operators, maxWitnesses, *workers)
+
+	calibrationResult := 0
+	for i, solution := range solutions {
+		if solution.Count == 0 {
+			continue
+		}
+		calibrationResult += testValues[i]
+
+		if maxWitnesses != 0 {
+			label := "assignment"
+			if solution.Count > 1 {
+				label = "assignments"
+			}
+			fmt.Printf("%d: %d satisfying %s\n", testValues[i], solution.Count, label)
+			for _, witness := range solution.Witnesses {
+				fmt.Printf("  %s\n", formatExpression(testValues[i], operandLists[i], witness))
+			}
 		}
 	}
 
 	fmt.Printf("Calibration Result: %d\n", calibrationResult)
 }
 
-func canProduceTestValue(testValue int, operands []int) bool {
-	if operands[0] > testValue {
+func registeredSymbols() string {
+	symbols := make([]string, len(operatorRegistry))
+	for i, operator := range operatorRegistry {
+		symbols[i] = operator.Symbol
+	}
+	return strings.Join(symbols, " ")
+}
+
+func chooseOperators(list string) ([]*Operator, error) {
+	var operators []*Operator
+	for _, symbol := range strings.Split(list, ",") {
+		found := false
+		for i := range operatorRegistry {
+			if operatorRegistry[i].Symbol == symbol {
+				operators = append(operators, &operatorRegistry[i])
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown operator %q", symbol)
+		}
+	}
+	return operators, nil
+}
+
+// solveAll solves the equations on a pool of workers, each with its own
+// Solver, and returns the solutions in input order.
+func solveAll(testValues []int, operandLists [][]int, operators []*Operator, maxWitnesses int, workers int) []Solution {
+	jobs := make(chan int)
+	solutions := make([]Solution, len(testValues))
+
+	var wg sync.WaitGroup
+	for range max(workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			solver := NewSolver(operators, maxWitnesses)
+			for i := range jobs {
+				solutions[i] = solver.Solve(testValues[i], operandLists[i])
+			}
+		}()
+	}
+
+	for i := range testValues {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return solutions
+}
+
+// Solver finds the operator assignments that make an equation true, keeping
+// the first maxWitnesses of them (all if maxWitnesses is negative). It reuses
+// its buffers between equations, so apart from recording witnesses and
+// growing the memo a solve doesn't allocate. A Solver is not safe for
+// concurrent use.
+type Solver struct {
+	operators    []*Operator
+	maxWitnesses int
+	reversible   bool // every operator has an Undo
+	monotone     bool // every operator is Monotone
+
+	testValue int
+	operands  []int
+	backward  bool
+	prune     bool
+	chosen    []*Operator
+	ways      map[[2]int]int // assignments that finish from each state seen
+	solution  Solution
+}
+
+func NewSolver(operators []*Operator, maxWitnesses int) *Solver {
+	s := &Solver{
+		operators:    operators,
+		maxWitnesses: maxWitnesses,
+		reversible:   true,
+		monotone:     true,
+		ways:         make(map[[2]int]int),
+	}
+	for _, operator := range operators {
+		s.reversible = s.reversible && operator.Undo != nil
+		s.monotone = s.monotone && operator.Monotone
+	}
+	return s
+}
+
+// Solve works right to left when it can, undoing operators from the test
+// value: every branch has to divide exactly or match the trailing digits, so
+// most die straight away and nothing can overflow. Times zero can't be
+// undone, but it only works when the value is 0, and then any assignment of
+// the operators before it does, so those are counted left to right instead.
+// With an operator that can't be undone at all the search falls back to
+// trying assignments left to right.
+//
+// Either way the search moves between states of an operand index and a
+// value, and many assignments pass through the same state, so the number of
+// ways to finish from each one is remembered rather than walked again.
+func (s *Solver) Solve(testValue int, operands []int) Solution {
+	s.solution = Solution{}
+	if len(operands) == 0 {
+		return s.solution
+	}
+
+	s.testValue = testValue
+	s.operands = operands
+	if cap(s.chosen) < len(operands)-1 {
+		s.chosen = make([]*Operator, len(operands)-1)
+	}
+	s.chosen = s.chosen[:len(operands)-1]
+	clear(s.ways)
+
+	// monotone operators only hold up their end for positive operands, and
+	// times zero can always drop back down
+	s.prune = s.monotone && operands[0] >= 0
+	s.backward = s.reversible
+	for _, operand := range operands[1:] {
+		s.prune = s.prune && operand > 0
+	}
+
+	start, value := 1, operands[0]
+	if s.backward {
+		start, value = len(operands)-1, testValue
+	}
+	if s.maxWitnesses == 0 {
+		if s.find(start, value) {
+			s.solution.Count = 1
+		}
+	} else {
+		s.solution.Count = s.countWays(start, value)
+		if s.solution.Count > 0 {
+			s.collect(start, value)
+		}
+	}
+
+	s.operands = nil
+	return s.solution
+}
+
+// step moves from state (i, value) by one operator. Going backward, state
+// (i, value) means operands[:i+1] must evaluate to value and the operator
+// before operands[i] is undone; free says operands[:i] may evaluate to
+// anything, as operands[i] absorbed it. Going forward, value is what
+// operands[:i] evaluate to and the operator before operands[i] is applied.
+// Either way it fills chosen[i-1].
+func (s *Solver) step(i int, value int, operator *Operator) (next int, nextValue int, free bool, ok bool) {
+	if s.backward {
+		if operator.Absorbs != nil {
+			if result, absorbs := operator.Absorbs(s.operands[i]); absorbs {
+				return i - 1, 0, true, value == result
+			}
+		}
+		previous, ok := operator.Undo(value, s.operands[i])
+		return i - 1, previous, false, ok
+	}
+	result, ok := operator.Apply(value, s.operands[i])
+	return i + 1, result, false, ok
+}
+
+// finished reports whether (i, value) is the end of the search, and if so
+// whether it satisfies the equation.
+func (s *Solver) finished(i int, value int) (bool, bool) {
+	if s.backward {
+		return i == 0, value == s.operands[0]
+	}
+	return i == len(s.operands), value == s.testValue
+}
+
+// hopeless is true when monotone operators can no longer reach the end: going
+// forward the value has passed the test value, going backward it is below
+// the first operand.
+func (s *Solver) hopeless(value int) bool {
+	if !s.prune {
 		return false
 	}
+	if s.backward {
+		return value < s.operands[0]
+	}
+	return value > s.testValue
+}
 
-	if len(operands) == 1 {
-		return operands[0] == testValue
+// find fills chosen with the first assignment that finishes from (i, value),
+// remembering the states that lead nowhere.
+func (s *Solver) find(i int, value int) bool {
+	if end, ok := s.finished(i, value); end {
+		return ok
+	}
+	key := [2]int{i, value}
+	if _, dead := s.ways[key]; dead || s.hopeless(value) {
+		return false
 	}
+	for _, operator := range s.operators {
+		j, next, free, ok := s.step(i, value, operator)
+		if !ok {
+			continue
+		}
+		s.chosen[i-1] = operator
+		if free && s.findFree(j) || !free && s.find(j, next) {
+			return true
+		}
+	}
+	s.ways[key] = 0
+	return false
+}
 
-	return canProduceTestValue(testValue, append([]int{operands[0] + operands[1]}, operands[2:]...)) ||
-		canProduceTestValue(testValue, append([]int{operands[0] * operands[1]}, operands[2:]...)) ||
-		canProduceTestValue(testValue, append([]int{concatInts(operands[0], operands[1])}, operands[2:]...))
+// countWays is the number of assignments that finish from (i, value).
+func (s *Solver) countWays(i int, value int) int {
+	if end, ok := s.finished(i, value); end {
+		if ok {
+			return 1
+		}
+		return 0
+	}
+	if s.hopeless(value) {
+		return 0
+	}
+	key := [2]int{i, value}
+	if ways, seen := s.ways[key]; seen {
+		return ways
+	}
+	ways := 0
+	for _, operator := range s.operators {
+		j, next, free, ok := s.step(i, value, operator)
+		if !ok {
+			continue
+		}
+		if free {
+			ways += s.countFree(j)
+		} else {
+			ways += s.countWays(j, next)
+		}
+	}
+	s.ways[key] = ways
+	return ways
 }
 
+// collect records witnesses from (i, value), only following states that
+// countWays found a way out of, and reports when it has as many as asked.
+func (s *Solver) collect(i int, value int) bool {
+	if end, _ := s.finished(i, value); end {
+		return s.record()
+	}
+	for _, operator := range s.operators {
+		j, next, free, ok := s.step(i, value, operator)
+		if !ok {
+			continue
+		}
+		s.chosen[i-1] = operator
+		if free && s.countFree(j) > 0 && s.collectFree(1, j) {
+			return true
+		}
+		if !free && s.countWays(j, next) > 0 && s.collect(j, next) {
+			return true
+		}
+	}
+	return false
+}
+
+// countFree is the number of assignments for the operators between
+// operands[:last+1] when any value will do, counted left to right. Whether
+// an operator can be applied only depends on its operand.
+func (s *Solver) countFree(last int) int {
+	ways := 1
+	for i := 1; i <= last; i++ {
+		ways *= s.usable(i)
+	}
+	return ways
+}
+
+func (s *Solver) usable(i int) int {
+	n := 0
+	for _, operator := range s.operators {
+		if _, ok := operator.Apply(0, s.operands[i]); ok {
+			n++
+		}
+	}
+	return n
+}
+
+// findFree fills chosen for operands[:last+1] with the first operators that
+// can be applied.
+func (s *Solver) findFree(last int) bool {
+	for i := 1; i <= last; i++ {
+		usable := false
+		for _, operator := range s.operators {
+			if _, ok := operator.Apply(0, s.operands[i]); ok {
+				s.chosen[i-1] = operator
+				usable = true
+				break
+			}
+		}
+		if !usable {
+			return false
+		}
+	}
+	return true
+}
+
+// collectFree records witnesses for every assignment of the operators from
+// the one before operands[i] up to operands[last].
+func (s *Solver) collectFree(i int, last int) bool {
+	if i > last {
+		return s.record()
+	}
+	for _, operator := range s.operators {
+		if _, ok := operator.Apply(0, s.operands[i]); ok {
+			s.chosen[i-1] = operator
+			if s.collectFree(i+1, last) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// record keeps chosen as a witness and reports when there are as many as
+// asked for.
+func (s *Solver) record() bool {
+	s.solution.Witnesses = append(s.solution.Witnesses, append([]*Operator{}, s.chosen...))
+	return len(s.solution.Witnesses) == s.maxWitnesses
+}
+
+// formatExpression writes an equation with its operators filled in, like
+// 190 = 10 * 19. It is evaluated left to right, so there are no parentheses.
+func formatExpression(testValue int, operands []int, operators []*Operator) string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "%d = %d", testValue, operands[0])
+	for i, operator := range operators {
+		fmt.Fprintf(&builder, " %s %d", operator.Symbol, operands[i+1])
+	}
+	return builder.String()
+}
+
+// concatInts joins the digits of i1 and a non-negative i2, keeping the sign
+// of i1, so -1 and 5 give -15.
 func concatInts(i1 int, i2 int) int {
-	result, _ := strconv.Atoi(strconv.Itoa(i1) + strconv.Itoa(i2))
-	return result
+	if i1 < 0 {
+		return i1*digitShift(i2) - i2
+	}
+	return i1*digitShift(i2) + i2
+}
+
+// digitShift is the power of ten that moves a number left past n's digits.
+func digitShift(n int) int {
+	shift := 10
+	for shift <= n {
+		shift *= 10
+	}
+	return shift
 }
 
 func parseLine(line string) (int, []int) {
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func mustChoose(t *testing.T, list string) []*Operator {
+	t.Helper()
+	operators, err := chooseOperators(list)
+	if err != nil {
+		t.Fatalf("chooseOperators(%q): %v", list, err)
+	}
+	return operators
+}
+
+func TestSample(t *testing.T) {
+	sample, err := os.ReadFile("sample.txt")
+	if err != nil {
+		t.Fatalf("reading sample: %v", err)
+	}
+	tests := []struct {
+		operators string
+		want      int
+	}{
+		{"+,*", 3749},
+		{"+,*,||", 11387},
+	}
+	for _, test := range tests {
+		solver := NewSolver(mustChoose(t, test.operators), 0)
+		total := 0
+		for _, line := range strings.Split(strings.TrimSpace(string(sample)), "\n") {
+			testValue, operands := parseLine(line)
+			if solver.Solve(testValue, operands).Count > 0 {
+				total += testValue
+			}
+		}
+		if total != test.want {
+			t.Errorf("%s: got %d, want %d", test.operators, total, test.want)
+		}
+	}
+}
+
+func TestCounts(t *testing.T) {
+	tests := []struct {
+		line      string
+		operators string
+		want      int
+	}{
+		{"3267: 81 40 27", "+,*", 2},
+		{"0: 5 3 0", "+,*", 2},
+		{"0: 0 0 0", "+,*", 4},
+		{"12: 0 3 4 0 12", "+,*", 5},
+		// 14 of the 31 operators are +, evaluated left to right
+		{"15: " + strings.Repeat("1 ", 32), "+,*", 265182525},
+		// a zero in the middle of a long equation used to force a search
+		// through every assignment
+		{"123456789: 3 3 3 3 3 3 3 3 3 3 3 0 2 2 2 2 2 2 2 2 2 2 2", "+,*,||", 0},
+	}
+	for _, test := range tests {
+		testValue, operands := parseLine(test.line)
+		operators := mustChoose(t, test.operators)
+		if got := NewSolver(operators, 1).Solve(testValue, operands).Count; got != test.want {
+			t.Errorf("%q with %s: got %d assignments, want %d", test.line, test.operators, got, test.want)
+		}
+		if got := NewSolver(operators, 0).Solve(testValue, operands).Count; got != min(test.want, 1) {
+			t.Errorf("%q with %s: got count %d without witnesses, want %d", test.line, test.operators, got, min(test.want, 1))
+		}
+	}
+}
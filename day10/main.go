@@ -3,6 +3,7 @@ package main
 import (
 	_ "embed"
 	"fmt"
+	"math/big"
 	"strconv"
 	"strings"
 )
@@ -28,7 +29,7 @@ var directions = []Coordinate{
 func main() {
 	var trailheads []Coordinate
 	totalScore := 0
-	totalRating := 0
+	totalRating := newCount(0)
 
 	lines := strings.Split(embeddedFile, "\n")
 	for i, line := range lines {
@@ -49,22 +50,22 @@ func main() {
 
 	for _, trailhead := range trailheads {
 		uniqueSummitLocations := make(map[Coordinate]struct{})
-		rating := 0
+		rating := newCount(0)
 
 		exploreTrail(trailhead, uniqueSummitLocations, &rating)
 
 		totalScore += len(uniqueSummitLocations)
-		totalRating += rating
+		totalRating = totalRating.Add(rating)
 	}
 
-	fmt.Printf("Total score: %d, Total rating: %d\n", totalScore, totalRating)
+	fmt.Printf("Total score: %d, Total rating: %s\n", totalScore, totalRating)
 }
 
-func exploreTrail(location Coordinate, uniqueSummitLocations map[Coordinate]struct{}, rating *int) {
+func exploreTrail(location Coordinate, uniqueSummitLocations map[Coordinate]struct{}, rating *Count) {
 	currentHeight := height(location)
 	if currentHeight == SUMMIT {
 		uniqueSummitLocations[location] = struct{}{}
-		*rating++
+		*rating = rating.Add(newCount(1))
 		return
 	}
 
@@ -95,3 +96,36 @@ func height(c Coordinate) int {
 func isOutOfBounds(c Coordinate) bool {
 	return c.row < 0 || c.column < 0 || c.row > len(grid)-1 || c.column > len(grid[0])-1
 }
+
+// Count is a trail rating, kept as a big.Int once it outgrows an int.
+type Count struct {
+	small int
+	large *big.Int
+}
+
+func newCount(n int) Count {
+	return Count{small: n}
+}
+
+func (c Count) Add(o Count) Count {
+	if c.large == nil && o.large == nil {
+		if sum := c.small + o.small; sum >= c.small {
+			return Count{small: sum}
+		}
+	}
+	return Count{large: new(big.Int).Add(c.toBig(), o.toBig())}
+}
+
+func (c Count) toBig() *big.Int {
+	if c.large != nil {
+		return c.large
+	}
+	return big.NewInt(int64(c.small))
+}
+
+func (c Count) String() string {
+	if c.large != nil {
+		return c.large.String()
+	}
+	return strconv.Itoa(c.small)
+}
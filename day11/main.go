@@ -2,7 +2,9 @@ package main
 
 import (
 	_ "embed"
+	"flag"
 	"fmt"
+	"math/big"
 	"strconv"
 	"strings"
 )
@@ -19,9 +21,19 @@ type StoneBlink struct {
 const BLINKS1 = 25
 const BLINKS2 = 75
 
-var rememberingStone = make(map[StoneBlink]int)
+var rememberingStone = make(map[StoneBlink]Count)
 
 func main() {
+	blinks2 := flag.Int("blinks", BLINKS2, "blinks to count the stones after for part 2")
+	flag.Parse()
+	if flag.NArg() > 0 {
+		fmt.Printf("Unexpected argument: %s\n", flag.Arg(0))
+		return
+	}
+	if *blinks2 < 0 {
+		fmt.Printf("Blinks can't be negative, got %d\n", *blinks2)
+		return
+	}
 
 	parts := strings.Split(embeddedFile, " ")
 	for _, part := range parts {
@@ -29,20 +41,20 @@ func main() {
 		inputStones = append(inputStones, num)
 	}
 
-	part1 := 0
-	part2 := 0
+	part1 := newCount(0)
+	part2 := newCount(0)
 	for _, engraving := range inputStones {
-		part1 += blink(engraving, BLINKS1)
-		part2 += blink(engraving, BLINKS2)
+		part1 = part1.Add(blink(engraving, BLINKS1))
+		part2 = part2.Add(blink(engraving, *blinks2))
 	}
-	fmt.Printf("Part 1: %d, Part 2: %d\n", part1, part2)
+	fmt.Printf("Part 1: %s, Part 2: %s\n", part1, part2)
 }
 
-func blink(engraving int, remainingBlinks int) int {
+func blink(engraving int, remainingBlinks int) Count {
 	observation := StoneBlink{engraving: engraving, blinksRemaining: remainingBlinks}
 
 	if remainingBlinks == 0 {
-		return 1 // we're done, count self
+		return newCount(1) // we're done, count self
 	}
 
 	memory, remembered := rememberingStone[observation]
@@ -51,15 +63,15 @@ func blink(engraving int, remainingBlinks int) int {
 	}
 
 	remainingBlinks--
-	stonesObserved := 0
+	var stonesObserved Count
 	if engraving == 0 {
 		stonesObserved = blink(1, remainingBlinks)
 	} else {
 		digits := numDigits(engraving)
 		if digits%2 == 0 {
 			left, right := splitNumber(engraving, digits)
-			stonesObserved = blink(left, remainingBlinks) +
-				blink(right, remainingBlinks)
+			stonesObserved = blink(left, remainingBlinks).Add(
+				blink(right, remainingBlinks))
 		} else {
 			stonesObserved = blink(engraving*2024, remainingBlinks)
 		}
@@ -94,3 +106,36 @@ func splitNumber(num int, digits int) (int, int) {
 	right := num % power
 	return left, right
 }
+
+// Count is a number of stones, kept as a big.Int once it outgrows an int.
+type Count struct {
+	small int
+	large *big.Int
+}
+
+func newCount(n int) Count {
+	return Count{small: n}
+}
+
+func (c Count) Add(o Count) Count {
+	if c.large == nil && o.large == nil {
+		if sum := c.small + o.small; sum >= c.small {
+			return Count{small: sum}
+		}
+	}
+	return Count{large: new(big.Int).Add(c.toBig(), o.toBig())}
+}
+
+func (c Count) toBig() *big.Int {
+	if c.large != nil {
+		return c.large
+	}
+	return big.NewInt(int64(c.small))
+}
+
+func (c Count) String() string {
+	if c.large != nil {
+		return c.large.String()
+	}
+	return strconv.Itoa(c.small)
+}
@@ -3,13 +3,15 @@ package main
 import (
 	"bufio"
 	"fmt"
+	"math/big"
 	"os"
+	"strconv"
 	"strings"
 )
 
 type DesignResult struct {
 	possible bool
-	total    int
+	total    Count
 }
 
 var cache = map[string]DesignResult{}
@@ -18,24 +20,24 @@ func main() {
 	towelPatterns, designs := parseInput()
 	fmt.Printf("Towel Patterns: %s\n", towelPatterns)
 
-	c := 0
+	c := newCount(0)
 	d := 0
 	for _, design := range designs {
 		fmt.Printf("Design: %s", design)
 		designResult := checkIfPossible(design, towelPatterns)
-		fmt.Printf(" - Possible: %d\n", designResult.total)
+		fmt.Printf(" - Possible: %s\n", designResult.total)
 		if designResult.possible {
 			d += 1
-			c += designResult.total
+			c = c.Add(designResult.total)
 		}
 	}
 
 	fmt.Printf("Designs Possible: %d\n", d)
-	fmt.Printf("Total Combinations: %d\n", c)
+	fmt.Printf("Total Combinations: %s\n", c)
 }
 
 func checkIfPossible(design string, towelPatterns []string) DesignResult {
-	designResult := DesignResult{possible: false, total: 0}
+	designResult := DesignResult{possible: false, total: newCount(0)}
 
 	if value, exists := cache[design]; exists {
 		return value
@@ -44,14 +46,14 @@ func checkIfPossible(design string, towelPatterns []string) DesignResult {
 	for _, pattern := range towelPatterns {
 		if design == pattern {
 			designResult.possible = true
-			designResult.total = designResult.total + 1
+			designResult.total = designResult.total.Add(newCount(1))
 		}
 
 		if strings.HasPrefix(design, pattern) {
 			result := checkIfPossible(design[len(pattern):], towelPatterns)
 			if result.possible {
 				designResult.possible = true
-				designResult.total = designResult.total + result.total
+				designResult.total = designResult.total.Add(result.total)
 			}
 		}
 
@@ -76,3 +78,36 @@ func parseInput() ([]string, []string) {
 
 	return towelPatterns, lines[2:]
 }
+
+// Count is a number of arrangements, kept as a big.Int once it outgrows an int.
+type Count struct {
+	small int
+	large *big.Int
+}
+
+func newCount(n int) Count {
+	return Count{small: n}
+}
+
+func (c Count) Add(o Count) Count {
+	if c.large == nil && o.large == nil {
+		if sum := c.small + o.small; sum >= c.small {
+			return Count{small: sum}
+		}
+	}
+	return Count{large: new(big.Int).Add(c.toBig(), o.toBig())}
+}
+
+func (c Count) toBig() *big.Int {
+	if c.large != nil {
+		return c.large
+	}
+	return big.NewInt(int64(c.small))
+}
+
+func (c Count) String() string {
+	if c.large != nil {
+		return c.large.String()
+	}
+	return strconv.Itoa(c.small)
+}
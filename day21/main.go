@@ -3,7 +3,11 @@ package main
 import (
 	"bufio"
 	_ "embed"
+	"flag"
 	"fmt"
+	"math"
+	"math/big"
+	"math/bits"
 	"os"
 	"strconv"
 	"strings"
@@ -15,47 +19,58 @@ type sequenceKey struct {
 	depth    int
 }
 
-var sequenceCache = make(map[sequenceKey]int)
+var sequenceCache = make(map[sequenceKey]Count)
 
 func main() {
+	robots := flag.Int("robots", 26, "keypads in the chain for part 2")
+	flag.Parse()
+	if flag.NArg() > 0 {
+		fmt.Printf("Unexpected argument: %s\n", flag.Arg(0))
+		return
+	}
+	if *robots < 0 {
+		fmt.Printf("Robots can't be negative, got %d\n", *robots)
+		return
+	}
+
 	codes := readInput()
 
 	// part 1
-	complexityScore := 0
+	complexityScore := newCount(0)
 	for _, code := range codes {
-		complexityScore += calculateScore(code, 3) // 3 robots
+		complexityScore = complexityScore.Add(calculateScore(code, 3)) // 3 robots
 	}
-	fmt.Printf("Part 1 - Total complexity score: %d\n", complexityScore)
+	fmt.Printf("Part 1 - Total complexity score: %s\n", complexityScore)
 
 	// part 2
-	complexityScore = 0
+	complexityScore = newCount(0)
 	for _, code := range codes {
-		complexityScore += calculateScore(code, 26) // 26 robots
+		complexityScore = complexityScore.Add(calculateScore(code, *robots)) // 26 robots unless overridden
 	}
-	fmt.Printf("Part 2 - Total complexity score: %d\n", complexityScore)
+	fmt.Printf("Part 2 - Total complexity score: %s\n", complexityScore)
 }
 
-func calculateScore(code string, robots int) int {
+func calculateScore(code string, robots int) Count {
 	numericCode, _ := codeToInteger(code)
 	length := getSequenceLength(code, robots)
-	return numericCode * length
+	return newCount(numericCode).Mul(length)
 }
 
-func getSequenceLength(targetSequence string, depth int) int {
+func getSequenceLength(targetSequence string, depth int) Count {
 	key := sequenceKey{sequence: targetSequence, depth: depth}
 	if value, exists := sequenceCache[key]; exists {
 		return value
 	}
 
-	length := 0
+	length := newCount(0)
 	if depth == 0 {
-		length = len(targetSequence)
+		length = newCount(len(targetSequence))
 	} else {
 		current := 'A'
 		for _, next := range targetSequence {
 			len := getMoveCount(current, next, depth)
 			current = next
-			length += len
+			length = length.Add(len)
 		}
 	}
 
@@ -63,9 +78,9 @@ func getSequenceLength(targetSequence string, depth int) int {
 	return length
 }
 
-func getMoveCount(current, next rune, depth int) int {
+func getMoveCount(current, next rune, depth int) Count {
 	if current == next {
-		return 1
+		return newCount(1)
 	}
 	newSequence := paths[buttonPair{first: current, second: next}]
 	return getSequenceLength(newSequence, depth-1)
@@ -97,6 +112,49 @@ func readInput() []string {
 	return lines
 }
 
+// Count is a sequence length or score, kept as a big.Int once it outgrows an int.
+type Count struct {
+	small int
+	large *big.Int
+}
+
+func newCount(n int) Count {
+	return Count{small: n}
+}
+
+func (c Count) Add(o Count) Count {
+	if c.large == nil && o.large == nil {
+		if sum := c.small + o.small; sum >= c.small {
+			return Count{small: sum}
+		}
+	}
+	return Count{large: new(big.Int).Add(c.toBig(), o.toBig())}
+}
+
+func (c Count) Mul(o Count) Count {
+	if c.large == nil && o.large == nil {
+		hi, lo := bits.Mul64(uint64(c.small), uint64(o.small))
+		if hi == 0 && lo <= math.MaxInt {
+			return Count{small: int(lo)}
+		}
+	}
+	return Count{large: new(big.Int).Mul(c.toBig(), o.toBig())}
+}
+
+func (c Count) toBig() *big.Int {
+	if c.large != nil {
+		return c.large
+	}
+	return big.NewInt(int64(c.small))
+}
+
+func (c Count) String() string {
+	if c.large != nil {
+		return c.large.String()
+	}
+	return strconv.Itoa(c.small)
+}
+
 type buttonPair struct {
 	first  rune
 	second rune